@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited step
+const (
+	OK      = "ok"
+	Failed  = "failed"
+	DryRun  = "dry_run"
+	Refused = "refused"
+	Skipped = "skipped"
+)
+
+// Event records one step of a change the exporter makes to a product or a token store
+type Event struct {
+	Time     time.Time `json:"time"`
+	Instance string    `json:"instance"`
+	Action   string    `json:"action"`
+	Step     string    `json:"step"`
+	Outcome  string    `json:"outcome"`
+	Detail   string    `json:"detail,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Auditor writes audit events as JSON lines to the exporter log and, when a path is set, appends them to a file
+type Auditor struct {
+	mu   sync.Mutex
+	path string
+}
+
+// New returns an auditor appending to the file at path, or only logging when path is empty
+func New(path string) *Auditor {
+	return &Auditor{path: path}
+}
+
+// Record timestamps and writes the event, err is recorded as the failure cause when set
+func (a *Auditor) Record(event Event, err error) {
+	event.Time = time.Now().UTC()
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	line, jsonErr := json.Marshal(event)
+	if jsonErr != nil {
+		log.Printf("Failed to encode audit event: %v", jsonErr)
+		return
+	}
+	log.Printf("audit: %s", line)
+
+	if a == nil || a.path == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := appendLine(a.path, line); err != nil {
+		log.Printf("Failed to write audit log %s: %v", a.path, err)
+	}
+}
+
+// appendLine appends line and a newline to the file at path, creating it readable only by the exporter
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error appending to audit log: %w", err)
+	}
+	return f.Close()
+}
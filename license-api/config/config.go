@@ -0,0 +1,473 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the declarative configuration of the license exporter
+type Config struct {
+	ListenAddress  string         `yaml:"listen_address"`
+	CacheTTL       time.Duration  `yaml:"cache_ttl"`
+	FetchTimeout   time.Duration  `yaml:"fetch_timeout"`
+	Vault          Vault          `yaml:"vault"`
+	GitLab         []GitLab       `yaml:"gitlab"`
+	Nexus          []Server       `yaml:"nexus"`
+	Sonar          []Server       `yaml:"sonar"`
+	AuditLog       string         `yaml:"audit_log"`
+	LicenseRenewal LicenseRenewal `yaml:"license_renewal"`
+}
+
+// DefaultInstance is the name of the instance configured through environment variables
+const DefaultInstance = "default"
+
+// GitLab holds the settings of one GitLab instance and where its access token is stored.
+// VaultPath is a shorthand for a vault-kv2 TokenStore at that path. The token is rotated once
+// fewer than RotationLeadDays of validity remain, checked every RotationCheckInterval.
+// A rotated token is written to StagingStore before it is committed to TokenStore, StagingStore
+// defaults to a "-staged" sibling of a Vault path, a ".staged" sibling of a file or a "-staging"
+// sibling of a Kubernetes Secret.
+// TokenType selects the rotate API, TokenGroup and TokenProject (ID or full path) own group,
+// project and group service account tokens. License keys are decoded with the PEM public key at
+// LicensePublicKeyFile before they are uploaded, renewal of the instance fails without it.
+// LegacyLicenseMetric keeps exporting the deprecated gitlab_license metric until it is removed.
+type GitLab struct {
+	Name                  string          `yaml:"name"`
+	URL                   string          `yaml:"url"`
+	VaultPath             string          `yaml:"vault_path"`
+	TokenStore            TokenStore      `yaml:"token_store"`
+	StagingStore          TokenStore      `yaml:"staging_store"`
+	TokenType             string          `yaml:"token_type"`
+	TokenGroup            string          `yaml:"token_group"`
+	TokenProject          string          `yaml:"token_project"`
+	TokenExpiryDays       int             `yaml:"token_expiry_days"`
+	RotationLeadDays      int             `yaml:"rotation_lead_days"`
+	RotationCheckInterval time.Duration   `yaml:"rotation_check_interval"`
+	TokenInventory        TokenInventory  `yaml:"token_inventory"`
+	SeatBreakdown         SeatBreakdown   `yaml:"seat_breakdown"`
+	InactiveUsers         InactiveUsers   `yaml:"inactive_users"`
+	Cost                  Cost            `yaml:"cost"`
+	LicenseHistory        Collector       `yaml:"license_history"`
+	AddOns                AddOns          `yaml:"add_ons"`
+	NamespaceQuotas       NamespaceQuotas `yaml:"namespace_quotas"`
+	LicensePublicKeyFile  string          `yaml:"license_public_key_file"`
+	LegacyLicenseMetric   bool            `yaml:"legacy_license_metric"`
+	TLS                   TLS             `yaml:"tls"`
+}
+
+// GitLab access token types
+const (
+	TokenPersonal       = "personal"
+	TokenGroup          = "group"
+	TokenProject        = "project"
+	TokenServiceAccount = "service_account"
+)
+
+// Token store types
+const (
+	StoreVaultKV2   = "vault-kv2"
+	StoreVaultKV1   = "vault-kv1"
+	StoreKubernetes = "kubernetes"
+	StoreFile       = "file"
+)
+
+// TokenStore selects where the GitLab rotation token is loaded from and persisted to
+type TokenStore struct {
+	Type      string `yaml:"type"`
+	Path      string `yaml:"path"`
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+}
+
+// IsVault reports whether the token is stored in Vault
+func (t TokenStore) IsVault() bool {
+	return t.Type == StoreVaultKV2 || t.Type == StoreVaultKV1
+}
+
+// Server holds the settings of one basic-auth protected instance such as Nexus or Sonar.
+// Password is never read from the file itself, only from PasswordFile or the environment.
+type Server struct {
+	Name         string `yaml:"name"`
+	URL          string `yaml:"url"`
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file"`
+	Password     string `yaml:"-"`
+	TLS          TLS    `yaml:"tls"`
+}
+
+// defaults returns the configuration used for anything not set in the file or environment
+func defaults() Config {
+	return Config{
+		ListenAddress: ":8081",
+		CacheTTL:      5 * time.Minute,
+		FetchTimeout:  30 * time.Second,
+		LicenseRenewal: LicenseRenewal{
+			Interval: 15 * time.Minute,
+		},
+	}
+}
+
+// defaultGitLab returns the settings used for anything not set on a GitLab instance
+func defaultGitLab(name string) GitLab {
+	return GitLab{
+		Name:                  name,
+		TokenType:             TokenPersonal,
+		TokenExpiryDays:       90,
+		RotationLeadDays:      14,
+		RotationCheckInterval: time.Hour,
+		InactiveUsers: InactiveUsers{
+			InactiveDays: 90,
+			Reclaim:      Reclaim{DryRun: true, Interval: 24 * time.Hour, MaxPerRun: 50},
+		},
+		Cost:                Cost{Currency: "USD", BillingPeriod: BillingAnnual},
+		NamespaceQuotas:     NamespaceQuotas{MaxNamespaces: 100, Concurrency: 4, NamespaceTimeout: 10 * time.Second},
+		LegacyLicenseMetric: true,
+	}
+}
+
+// defaultServer returns the settings used for anything not set on a Nexus or Sonar instance
+func defaultServer(name string) Server {
+	return Server{Name: name}
+}
+
+// UnmarshalYAML applies the instance defaults before decoding a GitLab list entry
+func (g *GitLab) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*g = defaultGitLab("")
+	type plain GitLab
+	return unmarshal((*plain)(g))
+}
+
+// UnmarshalYAML applies the instance defaults before decoding a Nexus or Sonar list entry
+func (s *Server) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = defaultServer("")
+	type plain Server
+	return unmarshal((*plain)(s))
+}
+
+// Load reads the config file at path (skipped when path is empty), applies environment
+// overrides, resolves credential files and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.Vault.Auth.normalize()
+	cfg.normalizeTokenStores()
+	cfg.normalizeCollectors()
+	if err := cfg.resolveCredentials(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// applyEnv lets the environment variables used before the config file existed override individual fields.
+// Per-vendor variables apply to the instance named "default", which GITLAB_URL, NEXUS_URL and SONAR_URL create if missing.
+func (c *Config) applyEnv() error {
+	setString := func(name string, field *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+
+	setTLS := func(prefix string, t *TLS) error {
+		setString(prefix+"_CA_FILE", &t.CAFile)
+		if v, ok := os.LookupEnv(prefix + "_TLS_INSECURE"); ok {
+			insecure, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s_TLS_INSECURE: %w", prefix, err)
+			}
+			t.InsecureSkipVerify = insecure
+		}
+		return nil
+	}
+
+	setString("LISTEN_ADDRESS", &c.ListenAddress)
+	setString("VAULT_URL", &c.Vault.URL)
+	setString("VAULT_AUTH_METHOD", &c.Vault.Auth.Method)
+	setString("VAULT_TOKEN", &c.Vault.Auth.Token)
+	setString("authRole", &c.Vault.Auth.Role)
+	if v, ok := os.LookupEnv("authPath"); ok {
+		c.Vault.Auth.Mount = mountFromLoginPath(v)
+	}
+	if err := setTLS("VAULT", &c.Vault.TLS); err != nil {
+		return err
+	}
+
+	if _, ok := os.LookupEnv("GITLAB_URL"); ok && c.gitLabInstance(DefaultInstance) == nil {
+		c.GitLab = append(c.GitLab, defaultGitLab(DefaultInstance))
+	}
+	if gl := c.gitLabInstance(DefaultInstance); gl != nil {
+		setString("GITLAB_URL", &gl.URL)
+		setString("VAULT_PATH", &gl.VaultPath)
+		if v, ok := os.LookupEnv("GL_TOKEN_EXPIRY_DAYS"); ok {
+			days, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("GL_TOKEN_EXPIRY_DAYS: %w", err)
+			}
+			gl.TokenExpiryDays = days
+		}
+		setString("GL_TOKEN_TYPE", &gl.TokenType)
+		setString("GL_TOKEN_GROUP", &gl.TokenGroup)
+		setString("GL_TOKEN_PROJECT", &gl.TokenProject)
+		if v, ok := os.LookupEnv("GL_ROTATION_LEAD_DAYS"); ok {
+			days, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("GL_ROTATION_LEAD_DAYS: %w", err)
+			}
+			gl.RotationLeadDays = days
+		}
+		if err := setTLS("GITLAB", &gl.TLS); err != nil {
+			return err
+		}
+	}
+
+	for _, vendor := range []struct {
+		prefix  string
+		servers *[]Server
+	}{{"NEXUS", &c.Nexus}, {"SONAR", &c.Sonar}} {
+		if _, ok := os.LookupEnv(vendor.prefix + "_URL"); ok && serverInstance(*vendor.servers, DefaultInstance) == nil {
+			*vendor.servers = append(*vendor.servers, defaultServer(DefaultInstance))
+		}
+		if s := serverInstance(*vendor.servers, DefaultInstance); s != nil {
+			setString(vendor.prefix+"_URL", &s.URL)
+			setString(vendor.prefix+"_USERNAME", &s.Username)
+			setString(vendor.prefix+"_PASSWORD", &s.Password)
+			if err := setTLS(vendor.prefix, &s.TLS); err != nil {
+				return err
+			}
+		}
+	}
+
+	setString("AUDIT_LOG", &c.AuditLog)
+
+	for name, field := range map[string]*time.Duration{"CACHE_TTL": &c.CacheTTL, "FETCH_TIMEOUT": &c.FetchTimeout} {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*field = d
+		}
+	}
+	return nil
+}
+
+// gitLabInstance returns the GitLab instance with the given name, or nil
+func (c *Config) gitLabInstance(name string) *GitLab {
+	for i := range c.GitLab {
+		if c.GitLab[i].Name == name {
+			return &c.GitLab[i]
+		}
+	}
+	return nil
+}
+
+// serverInstance returns the Nexus or Sonar instance with the given name, or nil
+func serverInstance(servers []Server, name string) *Server {
+	for i := range servers {
+		if servers[i].Name == name {
+			return &servers[i]
+		}
+	}
+	return nil
+}
+
+// normalizeTokenStores turns the vault_path shorthand into a vault-kv2 token store and defaults the
+// staging store to a sibling of the token store, so a staged token survives a restart.
+func (c *Config) normalizeTokenStores() {
+	for i := range c.GitLab {
+		store := &c.GitLab[i].TokenStore
+		if store.Type == "" {
+			store.Type = StoreVaultKV2
+		}
+		if store.IsVault() && store.Path == "" {
+			store.Path = c.GitLab[i].VaultPath
+		}
+
+		staging := &c.GitLab[i].StagingStore
+		if staging.Type != "" {
+			continue
+		}
+		switch {
+		case store.IsVault() && store.Path != "":
+			*staging = TokenStore{Type: store.Type, Path: store.Path + "-staged"}
+		case store.Type == StoreFile && store.Path != "":
+			*staging = TokenStore{Type: StoreFile, Path: store.Path + ".staged"}
+		case store.Type == StoreKubernetes && store.Name != "":
+			*staging = TokenStore{Type: StoreKubernetes, Namespace: store.Namespace, Name: store.Name + "-staging"}
+		}
+	}
+}
+
+// resolveCredentials reads password files for servers whose password was not set from the environment
+func (c *Config) resolveCredentials() error {
+	for vendor, servers := range map[string][]Server{"nexus": c.Nexus, "sonar": c.Sonar} {
+		for i := range servers {
+			s := &servers[i]
+			if s.Password != "" || s.PasswordFile == "" {
+				continue
+			}
+			data, err := os.ReadFile(s.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("%s[%s].password_file: %w", vendor, s.Name, err)
+			}
+			s.Password = strings.TrimSpace(string(data))
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports every problem found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ListenAddress == "" {
+		errs = append(errs, errors.New("listen_address: must not be empty"))
+	}
+	if c.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("cache_ttl: must not be negative, got %s", c.CacheTTL))
+	}
+	if c.FetchTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("fetch_timeout: must be positive, got %s", c.FetchTimeout))
+	}
+
+	names := make(map[string]bool)
+	for _, gl := range c.GitLab {
+		field := fmt.Sprintf("gitlab[%s]", gl.Name)
+		errs = append(errs, validateName(field, gl.Name, names))
+		errs = append(errs, validateURL(field+".url", gl.URL))
+		errs = append(errs, gl.TokenStore.validate(field+".token_store"))
+		if gl.StagingStore.Type == "" {
+			errs = append(errs, fmt.Errorf("%s.staging_store: required for token_store type %s", field, gl.TokenStore.Type))
+		} else {
+			errs = append(errs, gl.StagingStore.validate(field+".staging_store"))
+		}
+		errs = append(errs, gl.validateTokenType(field))
+		if gl.TokenExpiryDays <= 0 {
+			errs = append(errs, fmt.Errorf("%s.token_expiry_days: must be positive, got %d", field, gl.TokenExpiryDays))
+		}
+		// A lead time as long as the token lifetime would rotate on every check
+		if gl.RotationLeadDays < 0 || gl.RotationLeadDays >= gl.TokenExpiryDays {
+			errs = append(errs, fmt.Errorf("%s.rotation_lead_days: must be between 0 and token_expiry_days (%d), got %d", field, gl.TokenExpiryDays, gl.RotationLeadDays))
+		}
+		if gl.RotationCheckInterval <= 0 {
+			errs = append(errs, fmt.Errorf("%s.rotation_check_interval: must be positive, got %s", field, gl.RotationCheckInterval))
+		}
+	}
+	if c.UsesVault() {
+		errs = append(errs, validateURL("vault.url", c.Vault.URL))
+		errs = append(errs, c.Vault.validateAuth())
+	}
+	for vendor, servers := range map[string][]Server{"nexus": c.Nexus, "sonar": c.Sonar} {
+		names := make(map[string]bool)
+		for _, s := range servers {
+			field := fmt.Sprintf("%s[%s]", vendor, s.Name)
+			errs = append(errs, validateName(field, s.Name, names))
+			errs = append(errs, validateURL(field+".url", s.URL))
+		}
+	}
+
+	errs = append(errs, c.validateTLS())
+	errs = append(errs, c.validateCollectors())
+	errs = append(errs, c.LicenseRenewal.validate("license_renewal"))
+
+	return errors.Join(errs...)
+}
+
+// UsesVault reports whether any GitLab token is stored or staged in Vault, or license keys are read from it
+func (c *Config) UsesVault() bool {
+	if c.LicenseRenewal.Enabled && len(c.LicenseRenewal.VaultPaths) > 0 {
+		return true
+	}
+	for _, gl := range c.GitLab {
+		if gl.TokenStore.IsVault() || gl.StagingStore.IsVault() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTokenType checks that the group or project owning the token is set for its type
+func (g GitLab) validateTokenType(field string) error {
+	switch g.TokenType {
+	case TokenPersonal, TokenServiceAccount:
+		// Service account tokens are rotated through the group when token_group is set, otherwise as personal tokens
+	case TokenGroup:
+		if g.TokenGroup == "" {
+			return fmt.Errorf("%s.token_group: required for token_type %s", field, g.TokenType)
+		}
+	case TokenProject:
+		if g.TokenProject == "" {
+			return fmt.Errorf("%s.token_project: required for token_type %s", field, g.TokenType)
+		}
+	default:
+		return fmt.Errorf("%s.token_type: must be one of %s, %s, %s or %s, got %q", field, TokenPersonal, TokenGroup, TokenProject, TokenServiceAccount, g.TokenType)
+	}
+	return nil
+}
+
+// validate checks that the fields required by the store type are set
+func (t TokenStore) validate(field string) error {
+	switch t.Type {
+	case StoreVaultKV2, StoreVaultKV1:
+		if t.Path == "" {
+			return fmt.Errorf("%s.path: required for %s (or set vault_path)", field, t.Type)
+		}
+	case StoreFile:
+		if t.Path == "" {
+			return fmt.Errorf("%s.path: required for %s", field, t.Type)
+		}
+	case StoreKubernetes:
+		if t.Name == "" {
+			return fmt.Errorf("%s.name: required for %s", field, t.Type)
+		}
+	default:
+		return fmt.Errorf("%s.type: unknown store %q, want one of %s, %s, %s, %s", field, t.Type, StoreVaultKV2, StoreVaultKV1, StoreKubernetes, StoreFile)
+	}
+	return nil
+}
+
+// validateName checks that an instance name is set and unique within its vendor
+func validateName(field, name string, seen map[string]bool) error {
+	if name == "" {
+		return fmt.Errorf("%s.name: required", field)
+	}
+	if seen[name] {
+		return fmt.Errorf("%s.name: duplicate instance name", field)
+	}
+	seen[name] = true
+	return nil
+}
+
+// validateURL checks that raw is an absolute http(s) URL
+func validateURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute http(s) URL", field, raw)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitLabVault is a GitLab instance storing its token in Vault
+const gitLabVault = `
+vault:
+  url: https://vault:8200
+  auth:
+    role: license-exporter
+gitlab:
+  - name: main
+    url: https://gitlab.example.com
+    vault_path: secret/data/gitlab
+`
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		// wantErrs are substrings of the expected error, none when Load must succeed
+		wantErrs []string
+		check    func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			yaml: gitLabVault,
+			check: func(t *testing.T, cfg *Config) {
+				gl := cfg.GitLab[0]
+				if gl.TokenType != TokenPersonal || gl.TokenExpiryDays != 90 || gl.RotationLeadDays != 14 {
+					t.Errorf("GitLab defaults not applied: %+v", gl)
+				}
+				if gl.SeatBreakdown.CacheTTL != cfg.CacheTTL || gl.SeatBreakdown.FetchTimeout != cfg.FetchTimeout {
+					t.Errorf("collector durations = %s, %s, want the global %s, %s", gl.SeatBreakdown.CacheTTL, gl.SeatBreakdown.FetchTimeout, cfg.CacheTTL, cfg.FetchTimeout)
+				}
+				if cfg.Vault.Auth.Method != AuthJWT || cfg.Vault.Auth.Mount != AuthJWT || cfg.Vault.Auth.JWTFile != DefaultJWTFile {
+					t.Errorf("Vault auth defaults not applied: %+v", cfg.Vault.Auth)
+				}
+			},
+		},
+		{
+			name: "vault path becomes a kv2 store with a staged sibling",
+			yaml: gitLabVault,
+			check: func(t *testing.T, cfg *Config) {
+				gl := cfg.GitLab[0]
+				if want := (TokenStore{Type: StoreVaultKV2, Path: "secret/data/gitlab"}); gl.TokenStore != want {
+					t.Errorf("TokenStore = %+v, want %+v", gl.TokenStore, want)
+				}
+				if want := (TokenStore{Type: StoreVaultKV2, Path: "secret/data/gitlab-staged"}); gl.StagingStore != want {
+					t.Errorf("StagingStore = %+v, want %+v", gl.StagingStore, want)
+				}
+			},
+		},
+		{
+			name: "file store is staged next to it",
+			yaml: `
+gitlab:
+  - name: main
+    url: https://gitlab.example.com
+    token_store: {type: file, path: /var/lib/exporter/token.json}
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if want := (TokenStore{Type: StoreFile, Path: "/var/lib/exporter/token.json.staged"}); cfg.GitLab[0].StagingStore != want {
+					t.Errorf("StagingStore = %+v, want %+v", cfg.GitLab[0].StagingStore, want)
+				}
+				if cfg.UsesVault() {
+					t.Error("UsesVault() = true for file stores")
+				}
+			},
+		},
+		{
+			name: "configured staging store is kept",
+			yaml: gitLabVault + `    staging_store: {type: file, path: /data/staged.json}
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if want := (TokenStore{Type: StoreFile, Path: "/data/staged.json"}); cfg.GitLab[0].StagingStore != want {
+					t.Errorf("StagingStore = %+v, want %+v", cfg.GitLab[0].StagingStore, want)
+				}
+			},
+		},
+		{
+			name: "kubernetes store is staged in a sibling secret",
+			yaml: `
+gitlab:
+  - name: main
+    url: https://gitlab.example.com
+    token_store: {type: kubernetes, namespace: monitoring, name: gitlab-token}
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if want := (TokenStore{Type: StoreKubernetes, Namespace: "monitoring", Name: "gitlab-token-staging"}); cfg.GitLab[0].StagingStore != want {
+					t.Errorf("StagingStore = %+v, want %+v", cfg.GitLab[0].StagingStore, want)
+				}
+			},
+		},
+		{
+			name: "kubernetes store without a secret name",
+			yaml: `
+gitlab:
+  - name: main
+    url: https://gitlab.example.com
+    token_store: {type: kubernetes}
+`,
+			wantErrs: []string{
+				"gitlab[main].token_store.name: required for kubernetes",
+				"gitlab[main].staging_store: required for token_store type kubernetes",
+			},
+		},
+		{
+			name: "namespaces are de-duplicated",
+			yaml: gitLabVault + `    namespace_quotas:
+      namespaces: [platform, Platform, data, platform]
+`,
+			check: func(t *testing.T, cfg *Config) {
+				if got, want := cfg.GitLab[0].NamespaceQuotas.Namespaces, []string{"platform", "data"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("Namespaces = %q, want %q", got, want)
+				}
+			},
+		},
+		{
+			name: "nested namespaces are rejected",
+			yaml: gitLabVault + `    namespace_quotas:
+      namespaces: [platform/backend]
+`,
+			wantErrs: []string{`"platform/backend" is not a top-level namespace path`},
+		},
+		{
+			name: "environment overrides the default instance",
+			yaml: strings.Replace(gitLabVault, "name: main", "name: "+DefaultInstance, 1),
+			env: map[string]string{
+				"CACHE_TTL":            "1m",
+				"GL_TOKEN_EXPIRY_DAYS": "30",
+				"GL_TOKEN_TYPE":        TokenGroup,
+				"GL_TOKEN_GROUP":       "platform",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				gl := cfg.GitLab[0]
+				if cfg.CacheTTL != time.Minute || gl.TokenInventory.CacheTTL != time.Minute {
+					t.Errorf("cache TTL = %s, collector %s, want 1m", cfg.CacheTTL, gl.TokenInventory.CacheTTL)
+				}
+				if gl.TokenExpiryDays != 30 || gl.TokenType != TokenGroup || gl.TokenGroup != "platform" {
+					t.Errorf("GitLab = %+v, want the environment token settings", gl)
+				}
+			},
+		},
+		{
+			name: "environment creates the default instance",
+			env: map[string]string{
+				"GITLAB_URL":  "https://gitlab.example.com",
+				"VAULT_PATH":  "secret/data/gitlab",
+				"VAULT_URL":   "https://vault:8200",
+				"VAULT_TOKEN": "s.token",
+				"NEXUS_URL":   "https://nexus.example.com",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if len(cfg.GitLab) != 1 || cfg.GitLab[0].Name != DefaultInstance || cfg.GitLab[0].TokenStore.Path != "secret/data/gitlab" {
+					t.Errorf("GitLab = %+v, want the default instance", cfg.GitLab)
+				}
+				if len(cfg.Nexus) != 1 || cfg.Nexus[0].URL != "https://nexus.example.com" {
+					t.Errorf("Nexus = %+v, want the default instance", cfg.Nexus)
+				}
+				if cfg.Vault.Auth.Method != AuthToken {
+					t.Errorf("Vault auth method = %q, want %q when VAULT_TOKEN is set", cfg.Vault.Auth.Method, AuthToken)
+				}
+			},
+		},
+		{
+			name:     "invalid environment duration",
+			env:      map[string]string{"FETCH_TIMEOUT": "soon"},
+			wantErrs: []string{"FETCH_TIMEOUT"},
+		},
+		{
+			name:     "unknown field",
+			yaml:     "listen_addr: :9090\n",
+			wantErrs: []string{"field listen_addr not found"},
+		},
+		{
+			name: "every problem is reported",
+			yaml: `
+fetch_timeout: 0s
+gitlab:
+  - name: main
+    url: gitlab.example.com
+    token_store: {type: file, path: /token.json}
+    token_type: group
+    rotation_lead_days: 90
+`,
+			wantErrs: []string{
+				"fetch_timeout: must be positive",
+				"gitlab[main].url",
+				"gitlab[main].token_group: required for token_type group",
+				"gitlab[main].rotation_lead_days",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			path := ""
+			if tt.yaml != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := Load(path)
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("Load() succeeded, want %q", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error = %v, want %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+// TestLoadExample loads the shipped example with its files under /etc/license-exporter created in a temporary directory
+func TestLoadExample(t *testing.T) {
+	example, err := os.ReadFile("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cert, key := selfSignedCert(t)
+	for name, content := range map[string][]byte{
+		"ca.pem":         cert,
+		"client.pem":     cert,
+		"client-key.pem": key,
+		"nexus-password": []byte("nexus-secret\n"),
+		"sonar-password": []byte("sonar-secret\n"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, bytes.ReplaceAll(example, []byte("/etc/license-exporter/"), []byte(dir+"/")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.GitLab) != 3 || len(cfg.Nexus) != 1 || len(cfg.Sonar) != 1 {
+		t.Errorf("loaded %d GitLab, %d Nexus and %d Sonar instances, want 3, 1 and 1", len(cfg.GitLab), len(cfg.Nexus), len(cfg.Sonar))
+	}
+	for _, gl := range cfg.GitLab {
+		if gl.StagingStore.Type == "" {
+			t.Errorf("gitlab[%s] has no staging store", gl.Name)
+		}
+	}
+	if cfg.Nexus[0].Password != "nexus-secret" {
+		t.Errorf("Nexus password = %q, want it read from password_file", cfg.Nexus[0].Password)
+	}
+}
+
+// selfSignedCert returns a PEM encoded self-signed certificate and its key
+func selfSignedCert(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "license-exporter"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
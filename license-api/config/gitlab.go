@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Collector holds the settings shared by the optional GitLab collectors.
+// CacheTTL and FetchTimeout default to the global values when not set.
+type Collector struct {
+	Enabled      bool          `yaml:"enabled"`
+	CacheTTL     time.Duration `yaml:"cache_ttl"`
+	FetchTimeout time.Duration `yaml:"fetch_timeout"`
+}
+
+// TokenInventory lists the access tokens of a GitLab instance with the admin API.
+// Group and project access tokens are only listed for the configured Groups and Projects (ID or full path),
+// which also own the deploy tokens listed for them.
+type TokenInventory struct {
+	Collector      `yaml:",inline"`
+	Groups         []string `yaml:"groups"`
+	Projects       []string `yaml:"projects"`
+	IncludeRevoked bool     `yaml:"include_revoked"`
+}
+
+// SeatBreakdown counts the users of a GitLab instance by state and last activity with the admin API,
+// and the billable members of the configured top-level Groups. ActivityBucketDays are the upper bounds
+// of the last-activity buckets, in days.
+type SeatBreakdown struct {
+	Collector          `yaml:",inline"`
+	Groups             []string `yaml:"groups"`
+	ActivityBucketDays []int    `yaml:"activity_bucket_days"`
+}
+
+// InactiveUsers detects billable users without activity for InactiveDays
+type InactiveUsers struct {
+	Collector    `yaml:",inline"`
+	InactiveDays int     `yaml:"inactive_days"`
+	Reclaim      Reclaim `yaml:"reclaim"`
+}
+
+// Reclaim deactivates inactive users every Interval, at most MaxPerRun at a time. It is opt-in and
+// defaults to DryRun, which only records the users that would be deactivated. Bots, admins,
+// ExcludeUsernames and members of ExcludeGroups are never deactivated.
+type Reclaim struct {
+	Enabled          bool          `yaml:"enabled"`
+	DryRun           bool          `yaml:"dry_run"`
+	Interval         time.Duration `yaml:"interval"`
+	MaxPerRun        int           `yaml:"max_per_run"`
+	ExcludeUsernames []string      `yaml:"exclude_usernames"`
+	ExcludeGroups    []string      `yaml:"exclude_groups"`
+}
+
+// AddOns reads the add-on purchases of a GitLab instance, such as GitLab Duo seats, through GraphQL.
+// Assignments also pages through the users eligible for each add-on to export who holds a seat.
+type AddOns struct {
+	Collector   `yaml:",inline"`
+	Assignments bool `yaml:"assignments"`
+}
+
+// NamespaceQuotas exports the storage and CI/CD minute usage of top-level namespaces against their limits.
+// Namespaces lists the top-level paths or IDs to watch, duplicates are dropped on load and once resolved,
+// otherwise every top-level group whose path matches Include and not Exclude is watched, user namespaces
+// only with IncludeUsers. At most MaxNamespaces are exported. The usage of Concurrency namespaces is read
+// at a time, each within NamespaceTimeout, so one slow namespace does not hold up the others.
+type NamespaceQuotas struct {
+	Collector        `yaml:",inline"`
+	Namespaces       []string      `yaml:"namespaces"`
+	Include          string        `yaml:"include"`
+	Exclude          string        `yaml:"exclude"`
+	IncludeUsers     bool          `yaml:"include_users"`
+	MaxNamespaces    int           `yaml:"max_namespaces"`
+	Concurrency      int           `yaml:"concurrency"`
+	NamespaceTimeout time.Duration `yaml:"namespace_timeout"`
+}
+
+// validate checks the namespace filters and that the listed namespaces are top-level
+func (n NamespaceQuotas) validate(field string) error {
+	errs := []error{n.Collector.validate(field)}
+	for _, path := range n.Namespaces {
+		if path == "" || strings.Contains(path, "/") {
+			errs = append(errs, fmt.Errorf("%s.namespaces: %q is not a top-level namespace path", field, path))
+		}
+	}
+	for name, pattern := range map[string]string{"include": n.Include, "exclude": n.Exclude} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %w", field, name, err))
+		}
+	}
+	if n.MaxNamespaces <= 0 {
+		errs = append(errs, fmt.Errorf("%s.max_namespaces: must be positive, got %d", field, n.MaxNamespaces))
+	}
+	if n.Concurrency <= 0 {
+		errs = append(errs, fmt.Errorf("%s.concurrency: must be positive, got %d", field, n.Concurrency))
+	}
+	if n.NamespaceTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.namespace_timeout: must be positive, got %s", field, n.NamespaceTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// Billing periods of a Cost
+const (
+	BillingAnnual  = "annual"
+	BillingMonthly = "monthly"
+)
+
+// Cost prices the seats of a GitLab license. SeatPrice is the price of one seat and Budget the
+// amount available per BillingPeriod, in Currency. Plan, when set, must match the license plan
+// for the cost to be exported, so a renewal to another tier is not priced with the old price.
+type Cost struct {
+	Enabled       bool    `yaml:"enabled"`
+	SeatPrice     float64 `yaml:"seat_price"`
+	Currency      string  `yaml:"currency"`
+	BillingPeriod string  `yaml:"billing_period"`
+	Plan          string  `yaml:"plan"`
+	Budget        float64 `yaml:"budget"`
+}
+
+// validate checks the price, currency and billing period of an enabled cost model
+func (c Cost) validate(field string) error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.SeatPrice <= 0 {
+		errs = append(errs, fmt.Errorf("%s.seat_price: must be positive, got %g", field, c.SeatPrice))
+	}
+	if c.Currency == "" {
+		errs = append(errs, fmt.Errorf("%s.currency: must not be empty", field))
+	}
+	if c.BillingPeriod != BillingAnnual && c.BillingPeriod != BillingMonthly {
+		errs = append(errs, fmt.Errorf("%s.billing_period: must be %s or %s, got %q", field, BillingAnnual, BillingMonthly, c.BillingPeriod))
+	}
+	if c.Budget < 0 {
+		errs = append(errs, fmt.Errorf("%s.budget: must not be negative, got %g", field, c.Budget))
+	}
+	return errors.Join(errs...)
+}
+
+// minDeactivationDays is how long GitLab requires a user to be inactive before it can be deactivated
+const minDeactivationDays = 90
+
+// validate checks the inactivity threshold and the reclamation settings
+func (i InactiveUsers) validate(field string) error {
+	errs := []error{i.Collector.validate(field)}
+	if i.InactiveDays <= 0 {
+		errs = append(errs, fmt.Errorf("%s.inactive_days: must be positive, got %d", field, i.InactiveDays))
+	}
+	if !i.Reclaim.Enabled {
+		return errors.Join(errs...)
+	}
+	if !i.Enabled {
+		errs = append(errs, fmt.Errorf("%s.reclaim.enabled: requires %s.enabled", field, field))
+	}
+	if i.InactiveDays < minDeactivationDays {
+		errs = append(errs, fmt.Errorf("%s.inactive_days: GitLab only deactivates users inactive for %d days or more, got %d", field, minDeactivationDays, i.InactiveDays))
+	}
+	if i.Reclaim.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.reclaim.interval: must be positive, got %s", field, i.Reclaim.Interval))
+	}
+	if i.Reclaim.MaxPerRun <= 0 {
+		errs = append(errs, fmt.Errorf("%s.reclaim.max_per_run: must be positive, got %d", field, i.Reclaim.MaxPerRun))
+	}
+	return errors.Join(errs...)
+}
+
+// normalize applies the global cache TTL and fetch timeout to a collector that does not set its own
+func (c *Collector) normalize(cacheTTL, fetchTimeout time.Duration) {
+	if c.CacheTTL == 0 {
+		c.CacheTTL = cacheTTL
+	}
+	if c.FetchTimeout == 0 {
+		c.FetchTimeout = fetchTimeout
+	}
+}
+
+// validate checks the durations of a collector
+func (c Collector) validate(field string) error {
+	var errs []error
+	if c.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("%s.cache_ttl: must not be negative, got %s", field, c.CacheTTL))
+	}
+	if c.FetchTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.fetch_timeout: must not be negative, got %s", field, c.FetchTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// normalizeCollectors applies the global durations to the GitLab collectors
+func (c *Config) normalizeCollectors() {
+	for i := range c.GitLab {
+		gl := &c.GitLab[i]
+		gl.TokenInventory.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.SeatBreakdown.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.InactiveUsers.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.LicenseHistory.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.AddOns.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.NamespaceQuotas.normalize(c.CacheTTL, c.FetchTimeout)
+		gl.NamespaceQuotas.Namespaces = uniquePaths(gl.NamespaceQuotas.Namespaces)
+		if len(gl.SeatBreakdown.ActivityBucketDays) == 0 {
+			gl.SeatBreakdown.ActivityBucketDays = []int{30, 60, 90, 180}
+		}
+	}
+}
+
+// uniquePaths returns the paths without duplicates, in their first order. GitLab paths are not case
+// sensitive, a repeated namespace would be exported as duplicate series and fail the whole scrape.
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	var result []string
+	for _, path := range paths {
+		if key := strings.ToLower(path); !seen[key] {
+			seen[key] = true
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+// validateCollectors collects the errors of every GitLab collector
+func (c *Config) validateCollectors() error {
+	var errs []error
+	for _, gl := range c.GitLab {
+		field := fmt.Sprintf("gitlab[%s]", gl.Name)
+		errs = append(errs, gl.TokenInventory.validate(field+".token_inventory"))
+		errs = append(errs, gl.SeatBreakdown.validate(field+".seat_breakdown"))
+		errs = append(errs, gl.InactiveUsers.validate(field+".inactive_users"))
+		errs = append(errs, gl.Cost.validate(field+".cost"))
+		errs = append(errs, gl.LicenseHistory.validate(field+".license_history"))
+		errs = append(errs, gl.AddOns.validate(field+".add_ons"))
+		errs = append(errs, gl.NamespaceQuotas.validate(field+".namespace_quotas"))
+		for i, days := range gl.SeatBreakdown.ActivityBucketDays {
+			if days <= 0 || (i > 0 && days <= gl.SeatBreakdown.ActivityBucketDays[i-1]) {
+				errs = append(errs, fmt.Errorf("%s.seat_breakdown.activity_bucket_days: must be positive and increasing, got %v", field, gl.SeatBreakdown.ActivityBucketDays))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LicenseRenewal applies new license keys staged in Directory or at the Vault kv-v2 VaultPaths,
+// checked every Interval. A key is only applied when it expires after the license it replaces.
+// DryRun only records the keys that would be applied.
+type LicenseRenewal struct {
+	Enabled    bool          `yaml:"enabled"`
+	DryRun     bool          `yaml:"dry_run"`
+	Interval   time.Duration `yaml:"interval"`
+	Directory  string        `yaml:"directory"`
+	VaultPaths []string      `yaml:"vault_paths"`
+}
+
+// validate checks that an enabled renewal has an interval and somewhere to find keys
+func (r LicenseRenewal) validate(field string) error {
+	if !r.Enabled {
+		return nil
+	}
+	var errs []error
+	if r.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: must be positive, got %s", field, r.Interval))
+	}
+	if r.Directory == "" && len(r.VaultPaths) == 0 {
+		errs = append(errs, fmt.Errorf("%s: directory or vault_paths must be set", field))
+	}
+	for i, path := range r.VaultPaths {
+		if path == "" {
+			errs = append(errs, fmt.Errorf("%s.vault_paths[%d]: must not be empty", field, i))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS holds the TLS settings used when talking to a server.
+// Certificates are verified against the system roots plus CAFile unless InsecureSkipVerify is explicitly set.
+type TLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Build returns the crypto/tls configuration for the settings
+func (t TLS) Build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: no PEM certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cert_file/key_file: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// validate checks that the certificate files can be loaded
+func (t TLS) validate(field string) error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("%s.tls: cert_file and key_file must be set together", field)
+	}
+	if _, err := t.Build(); err != nil {
+		return fmt.Errorf("%s.tls.%w", field, err)
+	}
+	return nil
+}
+
+// validateTLS collects the TLS errors of every configured server
+func (c *Config) validateTLS() error {
+	var errs []error
+	if c.Vault.URL != "" {
+		errs = append(errs, c.Vault.TLS.validate("vault"))
+	}
+	for _, gl := range c.GitLab {
+		errs = append(errs, gl.TLS.validate(fmt.Sprintf("gitlab[%s]", gl.Name)))
+	}
+	for vendor, servers := range map[string][]Server{"nexus": c.Nexus, "sonar": c.Sonar} {
+		for _, s := range servers {
+			errs = append(errs, s.TLS.validate(fmt.Sprintf("%s[%s]", vendor, s.Name)))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Vault auth methods
+const (
+	AuthJWT        = "jwt"
+	AuthKubernetes = "kubernetes"
+	AuthAppRole    = "approle"
+	AuthToken      = "token"
+	AuthCert       = "cert"
+)
+
+// DefaultJWTFile is the service account token used by the jwt and kubernetes auth methods
+const DefaultJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Vault holds the Vault server and auth settings
+type Vault struct {
+	URL  string    `yaml:"url"`
+	Auth VaultAuth `yaml:"auth"`
+	TLS  TLS       `yaml:"tls"`
+}
+
+// VaultAuth selects how the exporter logs in to Vault. Mount defaults to the method name,
+// so the jwt method logs in at auth/jwt/login. The cert method uses vault.tls.cert_file and
+// key_file, with Role naming the certificate role to log in against.
+type VaultAuth struct {
+	Method       string `yaml:"method"`
+	Mount        string `yaml:"mount"`
+	Role         string `yaml:"role"`
+	JWTFile      string `yaml:"jwt_file"`
+	RoleIDFile   string `yaml:"role_id_file"`
+	SecretIDFile string `yaml:"secret_id_file"`
+	TokenFile    string `yaml:"token_file"`
+	Token        string `yaml:"-"`
+}
+
+// LoginPath returns the Vault path the auth method logs in at
+func (a VaultAuth) LoginPath() string {
+	return fmt.Sprintf("auth/%s/login", a.Mount)
+}
+
+// normalize fills in the method, mount and JWT file defaults
+func (a *VaultAuth) normalize() {
+	if a.Method == "" {
+		a.Method = AuthJWT
+		if a.Token != "" || a.TokenFile != "" {
+			a.Method = AuthToken
+		}
+	}
+	if a.Mount == "" {
+		a.Mount = a.Method
+	}
+	if a.JWTFile == "" && (a.Method == AuthJWT || a.Method == AuthKubernetes) {
+		a.JWTFile = DefaultJWTFile
+	}
+}
+
+// mountFromLoginPath turns a login path such as auth/jwt/login into its mount, jwt
+func mountFromLoginPath(path string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.Trim(path, "/"), "auth/"), "/login")
+}
+
+// validateAuth checks that the fields required by the auth method are set
+func (v Vault) validateAuth() error {
+	a := v.Auth
+	switch a.Method {
+	case AuthJWT, AuthKubernetes:
+		if a.Role == "" {
+			return fmt.Errorf("vault.auth.role: required for the %s auth method", a.Method)
+		}
+	case AuthAppRole:
+		if a.RoleIDFile == "" {
+			return errors.New("vault.auth.role_id_file: required for the approle auth method")
+		}
+	case AuthToken:
+		if a.Token == "" && a.TokenFile == "" {
+			return errors.New("vault.auth.token_file: required for the token auth method unless VAULT_TOKEN is set")
+		}
+	case AuthCert:
+		if v.TLS.CertFile == "" || v.TLS.KeyFile == "" {
+			return errors.New("vault.tls.cert_file, key_file: required for the cert auth method")
+		}
+	default:
+		return fmt.Errorf("vault.auth.method: unknown method %q, want one of %s, %s, %s, %s, %s", a.Method, AuthJWT, AuthKubernetes, AuthAppRole, AuthToken, AuthCert)
+	}
+	return nil
+}
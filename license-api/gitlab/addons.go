@@ -0,0 +1,318 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// duoAddOns maps the names of the GitLab Duo add-on purchases to the add-on type their seats are assigned by
+var duoAddOns = map[string]string{
+	"code_suggestions": "CODE_SUGGESTIONS",
+	"duo_enterprise":   "DUO_ENTERPRISE",
+}
+
+// addOnPurchasesQuery lists the add-ons purchased for the instance, %s adds the expiry field where GitLab has it
+const addOnPurchasesQuery = `query {
+  addOnPurchases {
+    id
+    name
+    purchasedQuantity
+    assignedQuantity
+    %s
+  }
+}`
+
+// addOnUsersQuery pages through the users eligible for a Duo add-on and their seat assignment
+const addOnUsersQuery = `query($addOnType: GitlabSubscriptionsAddOnType!, $purchaseIds: [GitlabSubscriptionsAddOnPurchaseID!]!, $after: String) {
+  selfManagedAddOnEligibleUsers(addOnType: $addOnType, addOnPurchaseIds: $purchaseIds, first: 100, after: $after) {
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    nodes {
+      username
+      lastActivityOn
+      addOnAssignments(addOnPurchaseIds: $purchaseIds) {
+        nodes {
+          addOnPurchase {
+            name
+          }
+        }
+      }
+    }
+  }
+}`
+
+// AddOnPurchase is one add-on purchased for the instance, such as GitLab Duo Pro seats.
+// ExpiresOn is only reported by GitLab versions that expose it.
+type AddOnPurchase struct {
+	ID                string          `json:"id"`
+	Name              string          `json:"name"`
+	PurchasedQuantity int             `json:"purchasedQuantity"`
+	AssignedQuantity  int             `json:"assignedQuantity"`
+	ExpiresOn         *gitlab.ISOTime `json:"expiresOn"`
+}
+
+// AddOnUser is a user eligible for a Duo add-on, Assigned when the user holds one of its seats
+type AddOnUser struct {
+	AddOn          string
+	Username       string
+	LastActivityOn *gitlab.ISOTime
+	Assigned       bool
+}
+
+// AddOnSeats is the add-on data of one GitLab instance
+type AddOnSeats struct {
+	Purchases []AddOnPurchase
+	// Users holds the users eligible for each Duo add-on, only listed when assignments are enabled
+	Users []AddOnUser
+}
+
+// AddOnPurchases reads the add-on purchases and Duo seat assignments of a GitLab instance through GraphQL
+// with the client of its license Source. Add-ons included in the license itself are exported by the Source.
+type AddOnPurchases struct {
+	source  *Source
+	cfg     config.AddOns
+	cache   *source.Cache
+	metrics addOnMetrics
+
+	// noExpiry is set once GitLab rejected the expiry field, so it is no longer queried
+	noExpiry atomic.Bool
+}
+
+// NewAddOnPurchases returns the add-on collector of the instance of s
+func NewAddOnPurchases(s *Source, cfg config.AddOns) *AddOnPurchases {
+	a := &AddOnPurchases{
+		source:  s,
+		cfg:     cfg,
+		metrics: newAddOnMetrics(s.Instance()),
+	}
+	a.cache = source.NewCache(a, cfg.CacheTTL, cfg.FetchTimeout)
+	return a
+}
+
+// Name returns the name of the collector.
+func (a *AddOnPurchases) Name() string {
+	return "gitlab_add_ons"
+}
+
+// Instance returns the configured instance name of the collector.
+func (a *AddOnPurchases) Instance() string {
+	return a.source.Instance()
+}
+
+// Fetch lists the add-on purchases and, when enabled, the Duo seat assignments. Details holds the AddOnSeats.
+func (a *AddOnPurchases) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := a.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	purchases, err := a.listPurchases(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+	seats := &AddOnSeats{Purchases: purchases}
+	if !a.cfg.Assignments {
+		return &source.Result{Details: seats}, nil
+	}
+
+	for _, purchase := range purchases {
+		addOnType, ok := duoAddOns[purchase.Name]
+		if !ok {
+			continue
+		}
+		users, err := listAddOnUsers(ctx, gitClient, purchase, addOnType)
+		if err != nil {
+			return nil, err
+		}
+		seats.Users = append(seats.Users, users...)
+	}
+	return &source.Result{Details: seats}, nil
+}
+
+// listPurchases returns the add-on purchases, querying again without the expiry on GitLab versions that lack it
+func (a *AddOnPurchases) listPurchases(ctx context.Context, gitClient *gitlab.Client) ([]AddOnPurchase, error) {
+	for {
+		expiryField := "expiresOn"
+		if a.noExpiry.Load() {
+			expiryField = ""
+		}
+
+		var data struct {
+			AddOnPurchases []AddOnPurchase `json:"addOnPurchases"`
+		}
+		err := graphQL(ctx, gitClient, fmt.Sprintf(addOnPurchasesQuery, expiryField), nil, &data)
+		var gqlErr *graphQLError
+		if expiryField != "" && errors.As(err, &gqlErr) && strings.Contains(gqlErr.Error(), expiryField) {
+			log.Printf("GitLab instance %s does not report add-on expiry, exporting add-ons without it", a.Instance())
+			a.noExpiry.Store(true)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list add-on purchases: %w", err)
+		}
+		return data.AddOnPurchases, nil
+	}
+}
+
+// listAddOnUsers pages through the users eligible for the add-on purchase and whether they hold a seat
+func listAddOnUsers(ctx context.Context, gitClient *gitlab.Client, purchase AddOnPurchase, addOnType string) ([]AddOnUser, error) {
+	var users []AddOnUser
+	var after interface{}
+	for {
+		var data struct {
+			Users struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					Username         string          `json:"username"`
+					LastActivityOn   *gitlab.ISOTime `json:"lastActivityOn"`
+					AddOnAssignments struct {
+						Nodes []struct{} `json:"nodes"`
+					} `json:"addOnAssignments"`
+				} `json:"nodes"`
+			} `json:"selfManagedAddOnEligibleUsers"`
+		}
+		variables := map[string]interface{}{
+			"addOnType":   addOnType,
+			"purchaseIds": []string{purchase.ID},
+			"after":       after,
+		}
+		if err := graphQL(ctx, gitClient, addOnUsersQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to list users eligible for add-on %s: %w", purchase.Name, err)
+		}
+
+		for _, node := range data.Users.Nodes {
+			users = append(users, AddOnUser{
+				AddOn:          purchase.Name,
+				Username:       node.Username,
+				LastActivityOn: node.LastActivityOn,
+				Assigned:       len(node.AddOnAssignments.Nodes) > 0,
+			})
+		}
+		if !data.Users.PageInfo.HasNextPage {
+			return users, nil
+		}
+		after = data.Users.PageInfo.EndCursor
+	}
+}
+
+// addOnMetrics holds the Prometheus descriptors of the add-ons for one instance
+type addOnMetrics struct {
+	purchasedSeats *prometheus.Desc
+	assignedSeats  *prometheus.Desc
+	expiresAt      *prometheus.Desc
+	eligibleUsers  *prometheus.Desc
+	assignment     *prometheus.Desc
+	lastActivity   *prometheus.Desc
+}
+
+// newAddOnMetrics creates the add-on descriptors with the instance as a constant label
+func newAddOnMetrics(instance string) addOnMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return addOnMetrics{
+		purchasedSeats: prometheus.NewDesc(
+			"gitlab_add_on_purchased_seats",
+			"Seats purchased for the GitLab add-on",
+			[]string{"add_on"},
+			constLabels,
+		),
+		assignedSeats: prometheus.NewDesc(
+			"gitlab_add_on_assigned_seats",
+			"Seats of the GitLab add-on assigned to users",
+			[]string{"add_on"},
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"gitlab_add_on_expiry_timestamp_seconds",
+			"Unix timestamp when the GitLab add-on purchase expires, absent when GitLab does not report it",
+			[]string{"add_on"},
+			constLabels,
+		),
+		eligibleUsers: prometheus.NewDesc(
+			"gitlab_add_on_eligible_users",
+			"Users eligible for a seat of the GitLab Duo add-on",
+			[]string{"add_on"},
+			constLabels,
+		),
+		assignment: prometheus.NewDesc(
+			"gitlab_add_on_seat_assignment",
+			"Users holding a seat of the GitLab Duo add-on",
+			[]string{"add_on", "username"},
+			constLabels,
+		),
+		lastActivity: prometheus.NewDesc(
+			"gitlab_add_on_assigned_user_last_activity_timestamp_seconds",
+			"Unix timestamp of the last activity of a user holding a seat of the GitLab Duo add-on",
+			[]string{"add_on", "username"},
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the add-on metric descriptors.
+func (a *AddOnPurchases) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.metrics.purchasedSeats
+	ch <- a.metrics.assignedSeats
+	ch <- a.metrics.expiresAt
+	ch <- a.metrics.eligibleUsers
+	ch <- a.metrics.assignment
+	ch <- a.metrics.lastActivity
+	a.cache.Describe(ch)
+}
+
+// Collect fetches the add-ons through the cache and sends them as Prometheus metrics.
+func (a *AddOnPurchases) Collect(ch chan<- prometheus.Metric) {
+	result, err := a.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch GitLab add-ons for instance %s: %v", a.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	seats := result.Details.(*AddOnSeats)
+	for _, purchase := range seats.Purchases {
+		ch <- prometheus.MustNewConstMetric(a.metrics.purchasedSeats, prometheus.GaugeValue, float64(purchase.PurchasedQuantity), purchase.Name)
+		ch <- prometheus.MustNewConstMetric(a.metrics.assignedSeats, prometheus.GaugeValue, float64(purchase.AssignedQuantity), purchase.Name)
+		if purchase.ExpiresOn != nil {
+			ch <- prometheus.MustNewConstMetric(a.metrics.expiresAt, prometheus.GaugeValue, float64(time.Time(*purchase.ExpiresOn).Unix()), purchase.Name)
+		}
+	}
+
+	if !a.cfg.Assignments {
+		return
+	}
+	eligible := make(map[string]int)
+	for _, purchase := range seats.Purchases {
+		if _, ok := duoAddOns[purchase.Name]; ok {
+			eligible[purchase.Name] = 0
+		}
+	}
+	for _, user := range seats.Users {
+		eligible[user.AddOn]++
+		if !user.Assigned {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(a.metrics.assignment, prometheus.GaugeValue, 1, user.AddOn, user.Username)
+		if user.LastActivityOn != nil {
+			ch <- prometheus.MustNewConstMetric(a.metrics.lastActivity, prometheus.GaugeValue, float64(time.Time(*user.LastActivityOn).Unix()), user.AddOn, user.Username)
+		}
+	}
+	for addOn, count := range eligible {
+		ch <- prometheus.MustNewConstMetric(a.metrics.eligibleUsers, prometheus.GaugeValue, float64(count), addOn)
+	}
+}
@@ -0,0 +1,127 @@
+package gitlab
+
+import (
+	"log"
+	"sync"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// costMetrics holds the Prometheus descriptors of the license cost model for one instance.
+// Amounts are per configured billing period and labelled with currency and period.
+type costMetrics struct {
+	seatPrice         *prometheus.Desc
+	projectedCost     *prometheus.Desc
+	trueUpCost        *prometheus.Desc
+	costPerActiveUser *prometheus.Desc
+	remainingBudget   *prometheus.Desc
+
+	planMismatch *planWarning
+}
+
+// planWarning logs a cost model priced for another plan once per license plan instead of on every scrape
+type planWarning struct {
+	mu     sync.Mutex
+	warned bool
+	// plan is the license plan last warned about
+	plan string
+}
+
+// warn logs the mismatch unless it was already logged for this license plan
+func (w *planWarning) warn(pricedFor, plan string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.warned && w.plan == plan {
+		return
+	}
+	w.warned, w.plan = true, plan
+	log.Printf("Skipping GitLab license cost: priced for plan %s, license plan is %s", pricedFor, plan)
+}
+
+// reset lets a later mismatch be logged again once the plans match
+func (w *planWarning) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warned = false
+}
+
+// newCostMetrics creates the cost descriptors with the instance as a constant label
+func newCostMetrics(instance string) costMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	costLabels := []string{"currency", "period"}
+	return costMetrics{
+		seatPrice: prometheus.NewDesc(
+			"gitlab_license_seat_price",
+			"Configured price of one Gitlab License seat",
+			costLabels,
+			constLabels,
+		),
+		projectedCost: prometheus.NewDesc(
+			"gitlab_license_projected_cost",
+			"Projected Gitlab License cost for the licensed seats plus the true-up seats",
+			costLabels,
+			constLabels,
+		),
+		trueUpCost: prometheus.NewDesc(
+			"gitlab_license_true_up_cost",
+			"Projected true-up cost of the historical maximum of users above the Gitlab License user limit",
+			costLabels,
+			constLabels,
+		),
+		costPerActiveUser: prometheus.NewDesc(
+			"gitlab_license_cost_per_active_user",
+			"Projected Gitlab License cost divided by the active users",
+			costLabels,
+			constLabels,
+		),
+		remainingBudget: prometheus.NewDesc(
+			"gitlab_license_remaining_budget",
+			"Configured budget minus the projected Gitlab License cost, negative when over budget",
+			costLabels,
+			constLabels,
+		),
+		planMismatch: &planWarning{},
+	}
+}
+
+// describe sends the cost metric descriptors
+func (m costMetrics) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.seatPrice
+	ch <- m.projectedCost
+	ch <- m.trueUpCost
+	ch <- m.costPerActiveUser
+	ch <- m.remainingBudget
+}
+
+// collect sends the cost of the license under the configured cost model.
+// Nothing is sent when the model is disabled or priced for another plan.
+func (m costMetrics) collect(ch chan<- prometheus.Metric, cost config.Cost, license License) {
+	if !cost.Enabled {
+		return
+	}
+	if cost.Plan != "" && cost.Plan != license.Plan {
+		m.planMismatch.warn(cost.Plan, license.Plan)
+		return
+	}
+	m.planMismatch.reset()
+
+	projected := float64(license.UserLimit+trueUpSeats(license)) * cost.SeatPrice
+	labels := []string{cost.Currency, cost.BillingPeriod}
+
+	ch <- prometheus.MustNewConstMetric(m.seatPrice, prometheus.GaugeValue, cost.SeatPrice, labels...)
+	ch <- prometheus.MustNewConstMetric(m.projectedCost, prometheus.GaugeValue, projected, labels...)
+	ch <- prometheus.MustNewConstMetric(m.trueUpCost, prometheus.GaugeValue, float64(trueUpSeats(license))*cost.SeatPrice, labels...)
+	if license.ActiveUsers > 0 {
+		ch <- prometheus.MustNewConstMetric(m.costPerActiveUser, prometheus.GaugeValue, projected/float64(license.ActiveUsers), labels...)
+	}
+	if cost.Budget > 0 {
+		ch <- prometheus.MustNewConstMetric(m.remainingBudget, prometheus.GaugeValue, cost.Budget-projected, labels...)
+	}
+}
+
+// trueUpSeats returns the seats charged at the seat price on true-up. True-ups are billed on the
+// historical maximum of billable users, GitLab's overage only reflects the current users.
+func trueUpSeats(license License) int {
+	return max(license.HistoricalMax-license.UserLimit, 0)
+}
@@ -0,0 +1,36 @@
+package gitlab
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCostPlanMismatchWarning(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	m := newCostMetrics("test")
+	cost := config.Cost{Enabled: true, Plan: "premium", SeatPrice: 29, Currency: "USD", BillingPeriod: config.BillingAnnual}
+	ch := make(chan prometheus.Metric, 10)
+
+	// Scrapes of one license plan warn once, a new mismatching plan and a mismatch after a match warn again
+	var warnings []int
+	for _, plan := range []string{"ultimate", "ultimate", "starter", "starter", "premium", "starter"} {
+		logs.Reset()
+		m.collect(ch, cost, License{Plan: plan, UserLimit: 10})
+		for len(ch) > 0 {
+			<-ch
+		}
+		warnings = append(warnings, strings.Count(logs.String(), "Skipping GitLab license cost"))
+	}
+	if want := []int{1, 0, 1, 0, 0, 1}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings per scrape = %v, want %v", warnings, want)
+	}
+}
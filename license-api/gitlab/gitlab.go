@@ -1,16 +1,21 @@
 package gitlab
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"os"
-
-	"github.com/hashicorp/vault/api"
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/gauravkr19/prometheus-exporters/vault"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/xanzy/go-gitlab"
 )
 
@@ -22,206 +27,422 @@ type Token struct {
 	Token     string
 }
 
-// func ReadVaultKV2 reads from Vault KV2 backend
-func ReadVaultKV2(vaultClient *api.Client, path string) *Token {
-	serviceAccountToken, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
+// SetupGitLab sets up the token store and reads the GitLab token from it.
+func SetupGitLab(ctx context.Context, cfg config.GitLab, session *vault.Session) (*gitlab.Client, *Token, secretstore.Store, error) {
+	store, err := NewTokenStore(cfg.TokenStore, session)
 	if err != nil {
-		log.Fatalf("Error reading service account token: %v", err)
+		return nil, nil, nil, err
 	}
-
-	// Authenticate with Vault using the Kubernetes/JWT auth method
-	authPath := os.Getenv("authPath")
-	authData := map[string]interface{}{
-		"role": os.Getenv("authRole"),
-		"jwt":  string(serviceAccountToken),
+	gitlabToken, err := ReadToken(ctx, store)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	secret, err := vaultClient.Logical().Write(authPath, authData)
+	gitClient, err := CreateGitLabClient(cfg, gitlabToken.Token)
 	if err != nil {
-		log.Fatalf("Error authenticating with Vault: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to create GitLab client: %w", err)
 	}
 
-	// Set the Vault token from the authentication response
-	vaultClient.SetToken(secret.Auth.ClientToken)
+	// Return gitClient, gitlabToken, and the token store
+	return gitClient, gitlabToken, store, nil
+}
 
-	// Read the secret from the KV2 backend
-	secret, err = vaultClient.Logical().Read(path)
+// gitClient to be created with every token refresh
+func CreateGitLabClient(cfg config.GitLab, token string) (*gitlab.Client, error) {
+	// Create a custom HTTP transport with the configured TLS settings
+	tlsConfig, err := cfg.TLS.Build()
 	if err != nil {
-		log.Fatalf("Error reading Vault KV2: %v", err)
+		return nil, err
 	}
-	data := secret.Data["data"].(map[string]interface{})
-
-	var id int
-	switch v := data["id"].(type) {
-	case string:
-		id, err = strconv.Atoi(v)
-		if err != nil {
-			log.Fatalf("Error converting id string to int: %v", err)
-		}
-	case float64:
-		id = int(v)
-	case json.Number:
-		id, err = strconv.Atoi(v.String())
-		if err != nil {
-			log.Fatalf("Error converting id json.Number to int: %v", err)
-		}
-	default:
-		log.Fatalf("Error converting id to int: unexpected type %T", v)
+	httpTransport := &http.Transport{
+		TLSClientConfig: tlsConfig,
 	}
 
-	expiresAt, ok := data["expires_at"].(string)
-	if !ok {
-		log.Fatalf("Error converting expires_at to string")
+	// Create a custom HTTP client with the custom transport
+	httpClient := &http.Client{
+		Transport: httpTransport,
 	}
 
-	var active bool
-	switch v := data["active"].(type) {
-	case string:
-		active, err = strconv.ParseBool(v)
-		if err != nil {
-			log.Fatalf("Error converting active string to bool: %v", err)
-		}
-	case bool:
-		active = v
-	default:
-		log.Fatalf("Error converting active to bool: unexpected type %T", v)
+	// Create a new GitLab client with the custom HTTP client
+	gitClient, err := gitlab.NewClient(token,
+		gitlab.WithBaseURL(cfg.URL),
+		gitlab.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
 	}
 
-	token, ok := data["token"].(string)
-	if !ok {
-		log.Fatalf("Error converting token to string")
-	}
+	return gitClient, nil
+}
 
-	return &Token{
-		ID:        id,
-		ExpiresAt: expiresAt,
-		Active:    active,
-		Token:     token,
+// TokenExpiryDays calculates the number of days until the token expires
+func (t *Token) TokenExpiryDays() (int, error) {
+	expiryTime, err := time.Parse("2006-01-02", t.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing ExpiresAt date: %w", err)
 	}
+	daysUntilExpiry := int(time.Until(expiryTime).Hours() / 24)
+	return daysUntilExpiry, nil
 }
 
-// SetupGitLab sets up Vault and reads the GitLab token from Vault.
-func SetupGitLab() (*gitlab.Client, *Token, *api.Client, string) {
-	vaultClient := CreateVaultClient()
-	vaultKVPath := os.Getenv("VAULT_PATH")
-	gitlabToken := ReadVaultKV2(vaultClient, vaultKVPath)
+// Source polls the license API of one GitLab instance. The access token is rotated by RunRotation.
+// Clients are set up lazily on first use so an unreachable token store or GitLab does not stop the exporter.
+type Source struct {
+	cfg          config.GitLab
+	session      *vault.Session
+	auditor      *audit.Auditor
+	fetchTimeout time.Duration
+	cache        *source.Cache
+	metrics      metrics
+
+	// setup admits one caller at a time to set the client up, the others wait until their context is done
+	setup chan struct{}
+
+	// mu guards the client, token and stores, which are replaced on rotation. It is never held during network I/O.
+	mu        sync.Mutex
+	gitClient *gitlab.Client
+	token     *Token
+	store     secretstore.Store
+	staging   secretstore.Store
+	// uncommitted is a rotated or recovered token that is not in the token store yet, unstaged when
+	// it is not in the staging store either. Its writes are retried on every rotation check.
+	uncommitted *Token
+	unstaged    bool
+}
 
-	gitClient, err := CreateGitLabClient(gitlabToken.Token)
-	if err != nil {
-		log.Fatalf("Failed to create GitLab client: %v", err)
+// NewSource returns a GitLab license source for one configured instance, caching fetched licenses for cacheTTL.
+// Token stores in Vault use the shared session, which may be nil when Vault is not used.
+func NewSource(cfg config.GitLab, session *vault.Session, auditor *audit.Auditor, cacheTTL, fetchTimeout time.Duration) *Source {
+	s := &Source{
+		cfg:          cfg,
+		session:      session,
+		auditor:      auditor,
+		fetchTimeout: fetchTimeout,
+		metrics:      newMetrics(cfg.Name),
+		setup:        make(chan struct{}, 1),
 	}
+	s.cache = source.NewCache(s, cacheTTL, fetchTimeout)
+	return s
+}
+
+// Name returns the vendor name of the source.
+func (s *Source) Name() string {
+	return "gitlab"
+}
 
-	// Return gitClient, gitlabToken, and vaultClient
-	return gitClient, gitlabToken, vaultClient, vaultKVPath
+// Instance returns the configured instance name of the source.
+func (s *Source) Instance() string {
+	return s.cfg.Name
 }
 
-// Create a Vault client
-func CreateVaultClient() *api.Client {
-	config := api.DefaultConfig()
-	config.Address = os.Getenv("VAULT_URL")
-	config.ConfigureTLS(&api.TLSConfig{Insecure: true})
+// client returns the GitLab client, setting it up on first use. A token rejected by GitLab is
+// replaced by the staged token when an interrupted rotation left a verified one behind.
+func (s *Source) client(ctx context.Context) (*gitlab.Client, error) {
+	if gitClient := s.currentClient(); gitClient != nil {
+		return gitClient, nil
+	}
+
+	select {
+	case s.setup <- struct{}{}:
+		defer func() { <-s.setup }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// Another caller may have set the client up while this one waited
+	if gitClient := s.currentClient(); gitClient != nil {
+		return gitClient, nil
+	}
 
-	client, err := api.NewClient(config)
+	gitClient, gitlabToken, store, err := SetupGitLab(ctx, s.cfg, s.session)
 	if err != nil {
-		log.Fatalf("Error creating Vault client: %v", err)
+		return nil, err
+	}
+	staging, err := NewTokenStore(s.cfg.StagingStore, s.session)
+	if err != nil {
+		return nil, err
 	}
-	return client
-}
 
-// gitClient to be created with every token refresh
-func CreateGitLabClient(token string) (*gitlab.Client, error) {
-	// Create a custom HTTP transport with InsecureSkipVerify set to true
-	httpTransport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	var uncommitted *Token
+	if err := VerifyToken(ctx, gitClient, gitlabToken); err != nil {
+		if source.ReasonOf(err) != source.ReasonAuth {
+			return nil, err
+		}
+		gitClient, gitlabToken, err = s.recoverStaged(ctx, staging, err)
+		if err != nil {
+			return nil, err
+		}
+		uncommitted = gitlabToken
 	}
 
-	// Create a custom HTTP client with the custom transport
-	httpClient := &http.Client{
-		Transport: httpTransport,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gitClient, s.token, s.store, s.staging = gitClient, gitlabToken, store, staging
+	s.uncommitted, s.unstaged = uncommitted, false
+	return s.gitClient, nil
+}
+
+// currentClient returns the client once it is set up, or nil
+func (s *Source) currentClient() *gitlab.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gitClient
+}
+
+// Fetch fetches the GitLab license information.
+func (s *Source) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := s.client(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a new GitLab client with the custom HTTP client
-	gitClient, err := gitlab.NewClient(token,
-		gitlab.WithBaseURL(os.Getenv("GITLAB_URL")),
-		gitlab.WithHTTPClient(httpClient))
+	// Get license information
+	license, err := getLicense(ctx, gitClient)
 	if err != nil {
 		return nil, err
 	}
 
-	return gitClient, nil
+	return &source.Result{
+		Plan:      license.Plan,
+		ExpiresAt: time.Time(*license.ExpiresAt),
+		Expired:   license.Expired,
+		Quantity:  license.UserLimit,
+		Details:   license,
+	}, nil
 }
 
-// TokenExpiryDays calculates the number of days until the token expires
-func (t *Token) TokenExpiryDays() int {
-	expiryTime, err := time.Parse("2006-01-02", t.ExpiresAt)
+// licenseResponse is the current license as returned by GET /license. The client only decodes the
+// legacy add-on codes, add_ons is decoded here as every add-on code with its quantity.
+type licenseResponse struct {
+	*gitlab.License
+	AddOns AddOns `json:"add_ons"`
+}
 
+// getLicense returns the current license with all of its add-ons
+func getLicense(ctx context.Context, gitClient *gitlab.Client) (*licenseResponse, error) {
+	req, err := gitClient.NewRequest(http.MethodGet, "license", nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
 	if err != nil {
-		log.Fatalf("Error parsing ExpiresAt date: %v", err)
+		return nil, err
 	}
-	daysUntilExpiry := int(time.Until(expiryTime).Hours() / 24)
-	return daysUntilExpiry
+	var license licenseResponse
+	if _, err := gitClient.Do(req, &license); err != nil {
+		return nil, apiError(fmt.Errorf("failed to get license: %w", err))
+	}
+	if license.License == nil || license.ExpiresAt == nil {
+		return nil, source.WithReason(source.ReasonNoLicense, errors.New("failed to get license: no license is installed"))
+	}
+	return &license, nil
 }
 
-// UpdateGitlabLicense fetches and registers GitLab license information.
-func UpdateGitlabLicense(gitClient *gitlab.Client) {
-	// Get license information
-	license, _, err := gitClient.License.GetLicense()
+// ApplyLicense uploads a license key to the GitLab instance. The key is decoded with the license public key
+// first, so a key that does not expire after current, or has fewer users on the same term or a lower plan,
+// is refused without being uploaded, as is a key whose terms differ from declared. A key that was already
+// uploaded, e.g. a future-dated license before a restart, is not uploaded twice. current is nil when no
+// license is installed.
+func (s *Source) ApplyLicense(ctx context.Context, key []byte, declared, current *source.Result) error {
+	if s.cfg.LicensePublicKeyFile == "" {
+		return errors.New("license_public_key_file is not set, the license key cannot be checked before it is uploaded")
+	}
+	publicKey, err := readLicensePublicKey(s.cfg.LicensePublicKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the license public key: %w", err)
+	}
+	decoded, err := decodeLicenseKey(key, publicKey)
 	if err != nil {
-		log.Fatalf("Failed to get license: %v", err)
+		return err
+	}
+	expiresAt, users := decoded.expiry(), decoded.Restrictions.ActiveUserCount
+	if expiresAt.IsZero() {
+		return source.WithReason(source.ReasonDowngrade, errors.New("license key has no expiry"))
+	}
+	if current != nil {
+		if expiresAt.Before(current.ExpiresAt) || expiresAt.Equal(current.ExpiresAt) && users <= current.Quantity {
+			return source.WithReason(source.ReasonDowngrade, fmt.Errorf("license key expires %s with %d users, not after the current license or with more users",
+				decoded.ExpiresAt, users))
+		}
+		if rank := planRanks[strings.ToLower(decoded.Restrictions.Plan)]; rank != 0 && rank < planRanks[strings.ToLower(current.Plan)] {
+			return source.WithReason(source.ReasonDowngrade, fmt.Errorf("license key is for the %s plan, the current license is %s",
+				decoded.Restrictions.Plan, current.Plan))
+		}
+	}
+	if err := source.CheckApplied(declared, &source.Result{ExpiresAt: expiresAt, Quantity: users}); err != nil {
+		return source.WithReason(source.ReasonMismatch, fmt.Errorf("license key was not uploaded: %w", err))
 	}
 
-	// Create a License instance and calculate DaysUntilExpiration
-	licenseInfo := NewLicense(license)
+	gitClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+	uploaded, err := listLicenses(ctx, gitClient)
+	if err != nil {
+		return err
+	}
+	if sameLicenseUploaded(uploaded, decoded) {
+		log.Printf("A license with the terms of the key was already uploaded to GitLab instance %s, not uploading it again", s.cfg.Name)
+		return nil
+	}
 
-	RegisterMetrics(licenseInfo)
+	added, _, err := gitClient.License.AddLicense(&gitlab.AddLicenseOptions{License: gitlab.Ptr(strings.TrimSpace(string(key)))}, gitlab.WithContext(ctx))
+	if err != nil {
+		return apiError(fmt.Errorf("failed to add license: %w", err))
+	}
+	s.cache.Invalidate()
+	if added.ExpiresAt == nil {
+		return source.WithReason(source.ReasonMismatch, fmt.Errorf("license %d was uploaded without an expiry", added.ID))
+	}
+	return source.CheckApplied(declared, &source.Result{ExpiresAt: time.Time(*added.ExpiresAt), Quantity: added.UserLimit})
 }
 
-// RotateTokenAndSetExpiry rotates the GitLab token and updates its expiry.
-func RotateTokenAndSetExpiry(gitClient *gitlab.Client, vaultClient *api.Client, token *Token) {
-
-	glTokenExpiryDays := 90
-	if envGLTokenExpiryDays, exists := os.LookupEnv("GL_TOKEN_EXPIRY_DAYS"); exists {
-		if val, err := strconv.Atoi(envGLTokenExpiryDays); err == nil {
-			glTokenExpiryDays = val
+// sameLicenseUploaded reports whether a license with the same plan, term and user limit as key was uploaded before
+func sameLicenseUploaded(uploaded []*gitlab.License, key *licenseKey) bool {
+	for _, license := range uploaded {
+		if strings.EqualFold(license.Plan, key.Restrictions.Plan) && license.UserLimit == key.Restrictions.ActiveUserCount &&
+			isoString(license.StartsAt) == key.startsAt() && isoString(license.ExpiresAt) == key.ExpiresAt {
+			return true
 		}
 	}
+	return false
+}
+
+// isoString formats an optional GitLab date, empty when it is not set
+func isoString(t *gitlab.ISOTime) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+// apiError attaches a source reason to an error returned by the GitLab API
+func apiError(err error) error {
+	var errResp *gitlab.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		return source.WithReason(source.StatusReason(errResp.Response.StatusCode), err)
+	}
+	// The client returns 404 responses as ErrNotFound rather than an ErrorResponse
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return source.WithReason(source.ReasonHTTPStatus, err)
+	}
+	return err
+}
 
-	newExpiryDate := gitlab.ISOTime(time.Now().AddDate(0, 0, glTokenExpiryDays))
-	rotateOptions := &gitlab.RotatePersonalAccessTokenOptions{
-		ExpiresAt: &newExpiryDate,
+// RotateToken rotates the GitLab token with the rotate API of its configured type and sets its new expiry.
+// GitLab revokes the old token immediately, the returned token is the only copy of the new one.
+// expires_at can be set on rotation for Gitlab vers 16.6 onwards.
+func RotateToken(ctx context.Context, gitClient *gitlab.Client, cfg config.GitLab, token *Token) (*Token, error) {
+	newExpiryDate := gitlab.ISOTime(time.Now().AddDate(0, 0, cfg.TokenExpiryDays))
+
+	var rotated *Token
+	var err error
+	switch cfg.TokenType {
+	case config.TokenGroup:
+		var groupToken *gitlab.GroupAccessToken
+		groupToken, _, err = gitClient.GroupAccessTokens.RotateGroupAccessToken(cfg.TokenGroup, token.ID,
+			&gitlab.RotateGroupAccessTokenOptions{ExpiresAt: &newExpiryDate}, gitlab.WithContext(ctx))
+		if err == nil {
+			rotated = accessToken(groupToken.ID, groupToken.Active, groupToken.Token, groupToken.ExpiresAt)
+		}
+	case config.TokenProject:
+		var projectToken *gitlab.ProjectAccessToken
+		projectToken, _, err = gitClient.ProjectAccessTokens.RotateProjectAccessToken(cfg.TokenProject, token.ID,
+			&gitlab.RotateProjectAccessTokenOptions{ExpiresAt: &newExpiryDate}, gitlab.WithContext(ctx))
+		if err == nil {
+			rotated = accessToken(projectToken.ID, projectToken.Active, projectToken.Token, projectToken.ExpiresAt)
+		}
+	case config.TokenServiceAccount:
+		if cfg.TokenGroup == "" {
+			// Instance service accounts rotate their tokens like personal tokens
+			rotated, err = rotatePersonalToken(ctx, gitClient, token, newExpiryDate)
+			break
+		}
+		rotated, err = rotateServiceAccountToken(ctx, gitClient, cfg.TokenGroup, token, newExpiryDate)
+	default:
+		rotated, err = rotatePersonalToken(ctx, gitClient, token, newExpiryDate)
+	}
+	if err != nil {
+		return nil, apiError(fmt.Errorf("failed to rotate %s access token: %w", cfg.TokenType, err))
 	}
 
-	// Rotate the personal access token, expires_at can be set for Gitlab vers 16.6 onwards
-	rotatedToken, _, err := gitClient.PersonalAccessTokens.RotatePersonalAccessTokenByID(token.ID, rotateOptions)
+	log.Printf("Successfully rotated Gitlab %s access token", cfg.TokenType)
+	return rotated, nil
+}
+
+// rotatePersonalToken rotates a personal access token by its ID
+func rotatePersonalToken(ctx context.Context, gitClient *gitlab.Client, token *Token, expiresAt gitlab.ISOTime) (*Token, error) {
+	pat, _, err := gitClient.PersonalAccessTokens.RotatePersonalAccessTokenByID(token.ID,
+		&gitlab.RotatePersonalAccessTokenOptions{ExpiresAt: &expiresAt}, gitlab.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Failed to rotate personal access token: %v", err)
+		return nil, err
 	}
+	return newToken(pat), nil
+}
+
+// rotateServiceAccountToken rotates the token of a group service account, looking up the
+// service account user through the token itself
+func rotateServiceAccountToken(ctx context.Context, gitClient *gitlab.Client, group string, token *Token, expiresAt gitlab.ISOTime) (*Token, error) {
+	self, _, err := gitClient.PersonalAccessTokens.GetSinglePersonalAccessToken(gitlab.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Error rotating token: %v", err)
+		return nil, err
 	}
 
-	log.Printf("Successfully rotated Gitlab token")
-	WriteTokenToVault(vaultClient, rotatedToken)
+	pat, _, err := gitClient.Groups.RotateServiceAccountPersonalAccessToken(group, self.UserID, token.ID,
+		gitlab.WithContext(ctx), withQuery("expires_at", expiresAt.String()))
+	if err != nil {
+		return nil, err
+	}
+	return newToken(pat), nil
 }
 
-// WriteTokenToVault writes the new token to Vault.
-func WriteTokenToVault(client *api.Client, rotatedToken *gitlab.PersonalAccessToken) {
-	vaultKVPath := os.Getenv("VAULT_PATH")
-	data := map[string]interface{}{
-		"id":         rotatedToken.ID,
-		"expires_at": rotatedToken.ExpiresAt.String(),
-		"active":     rotatedToken.Active,
-		"token":      rotatedToken.Token,
+// withQuery sets a query parameter the client options do not expose
+func withQuery(key, value string) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		query := req.URL.Query()
+		query.Set(key, value)
+		req.URL.RawQuery = query.Encode()
+		return nil
 	}
-	// Wrap data map inside another map with key "data" for KV-v2
-	payload := map[string]interface{}{
-		"data": data,
+}
+
+// listAll calls list for every page until GitLab reports no next page
+func listAll[T any](ctx context.Context, list func(page int, options ...gitlab.RequestOptionFunc) ([]T, *gitlab.Response, error)) ([]T, error) {
+	var all []T
+	for page := 1; page != 0; {
+		items, resp, err := list(page, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, apiError(err)
+		}
+		all = append(all, items...)
+		page = resp.NextPage
 	}
+	return all, nil
+}
 
-	secret, err := client.Logical().Write(vaultKVPath, payload)
+// VerifyToken checks with GitLab that the client authenticates as the given active token
+func VerifyToken(ctx context.Context, gitClient *gitlab.Client, token *Token) error {
+	self, _, err := gitClient.PersonalAccessTokens.GetSinglePersonalAccessToken(gitlab.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Error writing token to Vault KV2: %v", err)
+		return apiError(fmt.Errorf("failed to verify token: %w", err))
+	}
+	if self.ID != token.ID {
+		return fmt.Errorf("failed to verify token: GitLab reports token %d, expected %d", self.ID, token.ID)
+	}
+	if !self.Active || self.Revoked {
+		return source.WithReason(source.ReasonAuth, fmt.Errorf("failed to verify token: token %d is not active", self.ID))
+	}
+	return nil
+}
+
+// newToken converts a personal access token returned by the GitLab API into a Token
+func newToken(pat *gitlab.PersonalAccessToken) *Token {
+	return accessToken(pat.ID, pat.Active, pat.Token, pat.ExpiresAt)
+}
+
+// accessToken builds a Token from the fields shared by personal, group and project access tokens
+func accessToken(id int, active bool, value string, expiresAt *gitlab.ISOTime) *Token {
+	token := &Token{
+		ID:     id,
+		Active: active,
+		Token:  value,
+	}
+	if expiresAt != nil {
+		token.ExpiresAt = expiresAt.String()
 	}
-	log.Printf("Write response from Vault: %v", secret)
-	log.Printf("Successfully wrote Gitlab token to Vault backend")
+	return token
 }
@@ -0,0 +1,68 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+)
+
+// graphQLRequest is the body of a GraphQL query
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLError holds the errors GitLab reports in a GraphQL response, which is still sent with status 200
+type graphQLError struct {
+	Messages []string
+}
+
+func (e *graphQLError) Error() string {
+	return "GraphQL errors: " + strings.Join(e.Messages, "; ")
+}
+
+// graphQL runs query against the GraphQL API of the instance with the client's credentials and
+// decodes the data of the response into v. The API is served at /api/graphql, next to /api/v4.
+func graphQL(ctx context.Context, gitClient *gitlab.Client, query string, variables map[string]interface{}, v interface{}) error {
+	endpoint := *gitClient.BaseURL()
+	endpoint.Path = strings.TrimSuffix(strings.TrimSuffix(endpoint.Path, "/"), "/v4") + "/graphql"
+	endpoint.RawPath = ""
+
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := gitClient.Do(req, &response); err != nil {
+		return apiError(fmt.Errorf("GraphQL query failed: %w", err))
+	}
+	if len(response.Errors) > 0 {
+		gqlErr := &graphQLError{}
+		for _, e := range response.Errors {
+			gqlErr.Messages = append(gqlErr.Messages, e.Message)
+		}
+		return gqlErr
+	}
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		return source.WithReason(source.ReasonDecode, err)
+	}
+	return nil
+}
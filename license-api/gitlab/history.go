@@ -0,0 +1,192 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// LicenseRecords are the licenses uploaded to a GitLab instance and the ID of the active one
+type LicenseRecords struct {
+	Licenses []*gitlab.License
+	ActiveID int
+}
+
+// LicenseHistory lists every license uploaded to a GitLab instance with the client of its license Source,
+// so a renewal uploaded ahead of time can be confirmed before the current license lapses.
+type LicenseHistory struct {
+	source  *Source
+	cache   *source.Cache
+	metrics historyMetrics
+}
+
+// NewLicenseHistory returns the license history collector of the instance of s
+func NewLicenseHistory(s *Source, cfg config.Collector) *LicenseHistory {
+	h := &LicenseHistory{
+		source:  s,
+		metrics: newHistoryMetrics(s.Instance()),
+	}
+	h.cache = source.NewCache(h, cfg.CacheTTL, cfg.FetchTimeout)
+	return h
+}
+
+// Name returns the name of the collector.
+func (h *LicenseHistory) Name() string {
+	return "gitlab_license_history"
+}
+
+// Instance returns the configured instance name of the collector.
+func (h *LicenseHistory) Instance() string {
+	return h.source.Instance()
+}
+
+// Fetch lists the uploaded licenses and the active one, Details holds the LicenseRecords.
+func (h *LicenseHistory) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := h.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active, _, err := gitClient.License.GetLicense(gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, apiError(fmt.Errorf("failed to get license: %w", err))
+	}
+	licenses, err := listLicenses(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+	return &source.Result{Details: &LicenseRecords{Licenses: licenses, ActiveID: active.ID}}, nil
+}
+
+// listLicenses returns every license uploaded to the instance, GET /licenses is not covered by the client
+func listLicenses(ctx context.Context, gitClient *gitlab.Client) ([]*gitlab.License, error) {
+	licenses, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.License, *gitlab.Response, error) {
+		req, err := gitClient.NewRequest(http.MethodGet, "licenses", &gitlab.ListOptions{Page: page, PerPage: perPage}, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		var licenses []*gitlab.License
+		resp, err := gitClient.Do(req, &licenses)
+		if err != nil {
+			return nil, resp, err
+		}
+		return licenses, resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list licenses: %w", err)
+	}
+	return licenses, nil
+}
+
+// historyMetrics holds the Prometheus descriptors of the license history for one instance
+type historyMetrics struct {
+	active        *prometheus.Desc
+	startsAt      *prometheus.Desc
+	expiresAt     *prometheus.Desc
+	userLimit     *prometheus.Desc
+	nextStartsAt  *prometheus.Desc
+	nextExpiresAt *prometheus.Desc
+}
+
+// newHistoryMetrics creates the license history descriptors with the instance as a constant label
+func newHistoryMetrics(instance string) historyMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	licenseLabels := []string{"license_id", "plan"}
+	return historyMetrics{
+		active: prometheus.NewDesc(
+			"gitlab_license_history_active",
+			"Whether the uploaded Gitlab License is the active one",
+			licenseLabels,
+			constLabels,
+		),
+		startsAt: prometheus.NewDesc(
+			"gitlab_license_history_starts_at_timestamp_seconds",
+			"Unix timestamp when the uploaded Gitlab License starts",
+			licenseLabels,
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"gitlab_license_history_expiry_timestamp_seconds",
+			"Unix timestamp when the uploaded Gitlab License expires",
+			licenseLabels,
+			constLabels,
+		),
+		userLimit: prometheus.NewDesc(
+			"gitlab_license_history_user_limit",
+			"Users allowed by the uploaded Gitlab License",
+			licenseLabels,
+			constLabels,
+		),
+		nextStartsAt: prometheus.NewDesc(
+			"gitlab_license_next_starts_at_timestamp_seconds",
+			"Unix timestamp when the next future-dated Gitlab License starts, absent when none is uploaded",
+			nil,
+			constLabels,
+		),
+		nextExpiresAt: prometheus.NewDesc(
+			"gitlab_license_next_expiry_timestamp_seconds",
+			"Unix timestamp when the next future-dated Gitlab License expires, absent when none is uploaded",
+			nil,
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the license history metric descriptors.
+func (h *LicenseHistory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.metrics.active
+	ch <- h.metrics.startsAt
+	ch <- h.metrics.expiresAt
+	ch <- h.metrics.userLimit
+	ch <- h.metrics.nextStartsAt
+	ch <- h.metrics.nextExpiresAt
+	h.cache.Describe(ch)
+}
+
+// Collect lists the licenses through the cache and sends them as Prometheus metrics.
+func (h *LicenseHistory) Collect(ch chan<- prometheus.Metric) {
+	result, err := h.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to list GitLab licenses for instance %s: %v", h.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	records := result.Details.(*LicenseRecords)
+
+	// The next license is the earliest one starting after now, computed against the current time
+	var next *gitlab.License
+	now := time.Now()
+	for _, license := range records.Licenses {
+		id := strconv.Itoa(license.ID)
+		ch <- prometheus.MustNewConstMetric(h.metrics.active, prometheus.GaugeValue, boolValue(license.ID == records.ActiveID), id, license.Plan)
+		ch <- prometheus.MustNewConstMetric(h.metrics.userLimit, prometheus.GaugeValue, float64(license.UserLimit), id, license.Plan)
+		if license.ExpiresAt != nil {
+			ch <- prometheus.MustNewConstMetric(h.metrics.expiresAt, prometheus.GaugeValue, float64(time.Time(*license.ExpiresAt).Unix()), id, license.Plan)
+		}
+		if license.StartsAt == nil {
+			continue
+		}
+		startsAt := time.Time(*license.StartsAt)
+		ch <- prometheus.MustNewConstMetric(h.metrics.startsAt, prometheus.GaugeValue, float64(startsAt.Unix()), id, license.Plan)
+		if startsAt.After(now) && (next == nil || startsAt.Before(time.Time(*next.StartsAt))) {
+			next = license
+		}
+	}
+
+	if next != nil {
+		ch <- prometheus.MustNewConstMetric(h.metrics.nextStartsAt, prometheus.GaugeValue, float64(time.Time(*next.StartsAt).Unix()))
+		if next.ExpiresAt != nil {
+			ch <- prometheus.MustNewConstMetric(h.metrics.nextExpiresAt, prometheus.GaugeValue, float64(time.Time(*next.ExpiresAt).Unix()))
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// InactiveCounts is the result of one inactive user scan
+type InactiveCounts struct {
+	Inactive    int
+	Reclaimable int
+}
+
+// InactiveUsers detects billable users without recent activity with the client of its license Source
+// and, when reclamation is enabled, deactivates them through the admin API in RunReclaim.
+type InactiveUsers struct {
+	source  *Source
+	cfg     config.InactiveUsers
+	auditor *audit.Auditor
+	cache   *source.Cache
+	metrics inactiveMetrics
+}
+
+// NewInactiveUsers returns the inactive user collector of the instance of s
+func NewInactiveUsers(s *Source, cfg config.InactiveUsers) *InactiveUsers {
+	iu := &InactiveUsers{
+		source:  s,
+		cfg:     cfg,
+		auditor: s.auditor,
+		metrics: newInactiveMetrics(s.Instance()),
+	}
+	iu.cache = source.NewCache(iu, cfg.CacheTTL, cfg.FetchTimeout)
+	return iu
+}
+
+// Name returns the name of the collector.
+func (iu *InactiveUsers) Name() string {
+	return "gitlab_inactive_users"
+}
+
+// Instance returns the configured instance name of the collector.
+func (iu *InactiveUsers) Instance() string {
+	return iu.source.Instance()
+}
+
+// Fetch counts the inactive billable users, Details holds the InactiveCounts.
+func (iu *InactiveUsers) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := iu.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inactive, reclaimable, err := iu.find(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+	return &source.Result{Details: &InactiveCounts{Inactive: len(inactive), Reclaimable: len(reclaimable)}}, nil
+}
+
+// find returns the billable users inactive for the configured days, and those of them
+// that may be deactivated, least recently active first
+func (iu *InactiveUsers) find(ctx context.Context, gitClient *gitlab.Client) (inactive, reclaimable []*gitlab.User, err error) {
+	users, err := listUsers(ctx, gitClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -iu.cfg.InactiveDays)
+	for _, user := range billableUsers(users) {
+		if lastSeen(user).Before(cutoff) {
+			inactive = append(inactive, user)
+		}
+	}
+	sort.Slice(inactive, func(i, j int) bool {
+		return lastSeen(inactive[i]).Before(lastSeen(inactive[j]))
+	})
+
+	excluded, err := iu.excluded(ctx, gitClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, user := range inactive {
+		if !user.IsAdmin && !user.Bot && !excluded[user.Username] {
+			reclaimable = append(reclaimable, user)
+		}
+	}
+	return inactive, reclaimable, nil
+}
+
+// excluded returns the usernames that are never deactivated, including the members of the excluded groups
+func (iu *InactiveUsers) excluded(ctx context.Context, gitClient *gitlab.Client) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	for _, username := range iu.cfg.Reclaim.ExcludeUsernames {
+		excluded[username] = true
+	}
+
+	for _, group := range iu.cfg.Reclaim.ExcludeGroups {
+		members, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.GroupMember, *gitlab.Response, error) {
+			return gitClient.Groups.ListAllGroupMembers(group, &gitlab.ListGroupMembersOptions{
+				ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+			}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of excluded group %s: %w", group, err)
+		}
+		for _, member := range members {
+			excluded[member.Username] = true
+		}
+	}
+	return excluded, nil
+}
+
+// lastSeen returns when the user was last active, or created for users that were never active
+func lastSeen(user *gitlab.User) time.Time {
+	if last := lastActivity(user); last != nil {
+		return *last
+	}
+	if user.CreatedAt != nil {
+		return *user.CreatedAt
+	}
+	return time.Time{}
+}
+
+// RunReclaim deactivates inactive users every reclaim interval until ctx is done.
+// In dry-run mode the users are only recorded in the audit log.
+func (iu *InactiveUsers) RunReclaim(ctx context.Context) {
+	ticker := time.NewTicker(iu.cfg.Reclaim.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := iu.reclaim(ctx); err != nil {
+			log.Printf("Seat reclamation failed for GitLab instance %s: %v", iu.Instance(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reclaim deactivates up to max_per_run reclaimable users, least recently active first
+func (iu *InactiveUsers) reclaim(ctx context.Context) error {
+	findCtx, cancel := context.WithTimeout(ctx, iu.cfg.FetchTimeout)
+	defer cancel()
+
+	gitClient, err := iu.source.client(findCtx)
+	if err != nil {
+		reclaimFailuresMetric.WithLabelValues(iu.Instance()).Inc()
+		return err
+	}
+	_, reclaimable, err := iu.find(findCtx, gitClient)
+	if err != nil {
+		reclaimFailuresMetric.WithLabelValues(iu.Instance()).Inc()
+		return err
+	}
+
+	if len(reclaimable) > iu.cfg.Reclaim.MaxPerRun {
+		log.Printf("Found %d reclaimable users on GitLab instance %s, deactivating the first %d", len(reclaimable), iu.Instance(), iu.cfg.Reclaim.MaxPerRun)
+		reclaimable = reclaimable[:iu.cfg.Reclaim.MaxPerRun]
+	}
+	reclaimCandidatesMetric.WithLabelValues(iu.Instance()).Set(float64(len(reclaimable)))
+
+	for _, user := range reclaimable {
+		seen := "never"
+		if t := lastSeen(user); !t.IsZero() {
+			seen = t.Format("2006-01-02")
+		}
+		event := audit.Event{
+			Instance: iu.Instance(),
+			Action:   "user_deactivation",
+			Step:     "deactivate",
+			Detail:   fmt.Sprintf("user %s (%d) last seen %s", user.Username, user.ID, seen),
+		}
+		if iu.cfg.Reclaim.DryRun {
+			event.Outcome = audit.DryRun
+			iu.auditor.Record(event, nil)
+			continue
+		}
+
+		deactivateCtx, cancel := context.WithTimeout(ctx, iu.source.fetchTimeout)
+		err := gitClient.Users.DeactivateUser(user.ID, gitlab.WithContext(deactivateCtx))
+		cancel()
+		if err != nil {
+			event.Outcome = audit.Failed
+			iu.auditor.Record(event, apiError(err))
+			reclaimFailuresMetric.WithLabelValues(iu.Instance()).Inc()
+			continue
+		}
+		event.Outcome = audit.OK
+		iu.auditor.Record(event, nil)
+		deactivatedUsersMetric.WithLabelValues(iu.Instance()).Inc()
+	}
+
+	reclaimLastRunMetric.WithLabelValues(iu.Instance()).SetToCurrentTime()
+	return nil
+}
+
+// inactiveMetrics holds the Prometheus descriptors of the inactive user scan for one instance
+type inactiveMetrics struct {
+	inactive    *prometheus.Desc
+	reclaimable *prometheus.Desc
+}
+
+// newInactiveMetrics creates the inactive user descriptors with the instance as a constant label
+func newInactiveMetrics(instance string) inactiveMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return inactiveMetrics{
+		inactive: prometheus.NewDesc(
+			"gitlab_inactive_billable_users",
+			"GitLab users holding a license seat without activity for the configured number of days",
+			nil,
+			constLabels,
+		),
+		reclaimable: prometheus.NewDesc(
+			"gitlab_reclaimable_users",
+			"Inactive billable GitLab users that are not excluded from deactivation",
+			nil,
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the inactive user metric descriptors.
+func (iu *InactiveUsers) Describe(ch chan<- *prometheus.Desc) {
+	ch <- iu.metrics.inactive
+	ch <- iu.metrics.reclaimable
+	iu.cache.Describe(ch)
+}
+
+// Collect counts the inactive users through the cache and sends them as Prometheus metrics.
+func (iu *InactiveUsers) Collect(ch chan<- prometheus.Metric) {
+	result, err := iu.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to find inactive GitLab users for instance %s: %v", iu.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	counts := result.Details.(*InactiveCounts)
+	ch <- prometheus.MustNewConstMetric(iu.metrics.inactive, prometheus.GaugeValue, float64(counts.Inactive))
+	ch <- prometheus.MustNewConstMetric(iu.metrics.reclaimable, prometheus.GaugeValue, float64(counts.Reclaimable))
+}
@@ -0,0 +1,316 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Access token types reported by the inventory
+const (
+	tokenTypePersonal = "personal"
+	tokenTypeGroup    = "group"
+	tokenTypeProject  = "project"
+	tokenTypeDeploy   = "deploy"
+)
+
+// perPage is the page size used when listing from the GitLab API
+const perPage = 100
+
+// AccessToken is one token found by the inventory. Owner is user/<id> for personal tokens and
+// group/<full path> or project/<full path> for group and project tokens, as well as for deploy tokens
+// of the configured groups and projects. Other deploy tokens have no owner, GitLab does not list it.
+type AccessToken struct {
+	ID        int
+	Type      string
+	Name      string
+	Owner     string
+	Scopes    []string
+	Active    bool
+	Revoked   bool
+	ExpiresAt *time.Time
+}
+
+// State returns active, inactive (expired) or revoked
+func (t AccessToken) State() string {
+	switch {
+	case t.Revoked:
+		return "revoked"
+	case t.Active:
+		return "active"
+	default:
+		return "inactive"
+	}
+}
+
+// TokenInventory lists the personal, group, project and deploy tokens of a GitLab instance
+// with the client of its license Source. It needs an admin token.
+type TokenInventory struct {
+	source  *Source
+	cfg     config.TokenInventory
+	cache   *source.Cache
+	metrics inventoryMetrics
+}
+
+// NewTokenInventory returns the token inventory collector of the instance of s
+func NewTokenInventory(s *Source, cfg config.TokenInventory) *TokenInventory {
+	inv := &TokenInventory{
+		source:  s,
+		cfg:     cfg,
+		metrics: newInventoryMetrics(s.Instance()),
+	}
+	inv.cache = source.NewCache(inv, cfg.CacheTTL, cfg.FetchTimeout)
+	return inv
+}
+
+// Name returns the name of the collector.
+func (inv *TokenInventory) Name() string {
+	return "gitlab_tokens"
+}
+
+// Instance returns the configured instance name of the collector.
+func (inv *TokenInventory) Instance() string {
+	return inv.source.Instance()
+}
+
+// Fetch lists the access tokens, Details holds them as []AccessToken.
+func (inv *TokenInventory) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := inv.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group and project tokens belong to bot users and are also listed as personal tokens,
+	// the group and project listings replace them so they are reported with their owner
+	tokens := make(map[int]AccessToken)
+
+	pats, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.PersonalAccessToken, *gitlab.Response, error) {
+		if !inv.cfg.IncludeRevoked {
+			options = append(options, withQuery("revoked", "false"))
+		}
+		return gitClient.PersonalAccessTokens.ListPersonalAccessTokens(&gitlab.ListPersonalAccessTokensOptions{
+			ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+		}, options...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", err)
+	}
+	for _, t := range pats {
+		tokens[t.ID] = AccessToken{
+			ID: t.ID, Type: tokenTypePersonal, Name: t.Name, Owner: "user/" + strconv.Itoa(t.UserID),
+			Scopes: t.Scopes, Active: t.Active, Revoked: t.Revoked, ExpiresAt: isoTime(t.ExpiresAt),
+		}
+	}
+
+	// Deploy tokens are listed instance-wide without their owner, which only the group and project listings tell
+	deployOwners := make(map[int]string)
+
+	for _, group := range inv.cfg.Groups {
+		g, _, err := gitClient.Groups.GetGroup(group, &gitlab.GetGroupOptions{WithProjects: gitlab.Ptr(false)}, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, apiError(fmt.Errorf("failed to get group %s: %w", group, err))
+		}
+		owner := "group/" + g.FullPath
+
+		groupTokens, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.GroupAccessToken, *gitlab.Response, error) {
+			return gitClient.GroupAccessTokens.ListGroupAccessTokens(g.ID, &gitlab.ListGroupAccessTokensOptions{Page: page, PerPage: perPage}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access tokens of group %s: %w", g.FullPath, err)
+		}
+		for _, t := range groupTokens {
+			tokens[t.ID] = AccessToken{
+				ID: t.ID, Type: tokenTypeGroup, Name: t.Name, Owner: owner,
+				Scopes: t.Scopes, Active: t.Active, Revoked: t.Revoked, ExpiresAt: isoTime(t.ExpiresAt),
+			}
+		}
+
+		groupDeployTokens, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.DeployToken, *gitlab.Response, error) {
+			return gitClient.DeployTokens.ListGroupDeployTokens(g.ID, &gitlab.ListGroupDeployTokensOptions{Page: page, PerPage: perPage}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deploy tokens of group %s: %w", g.FullPath, err)
+		}
+		for _, t := range groupDeployTokens {
+			deployOwners[t.ID] = owner
+		}
+	}
+
+	for _, project := range inv.cfg.Projects {
+		p, _, err := gitClient.Projects.GetProject(project, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, apiError(fmt.Errorf("failed to get project %s: %w", project, err))
+		}
+		owner := "project/" + p.PathWithNamespace
+
+		projectTokens, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.ProjectAccessToken, *gitlab.Response, error) {
+			return gitClient.ProjectAccessTokens.ListProjectAccessTokens(p.ID, &gitlab.ListProjectAccessTokensOptions{Page: page, PerPage: perPage}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access tokens of project %s: %w", p.PathWithNamespace, err)
+		}
+		for _, t := range projectTokens {
+			tokens[t.ID] = AccessToken{
+				ID: t.ID, Type: tokenTypeProject, Name: t.Name, Owner: owner,
+				Scopes: t.Scopes, Active: t.Active, Revoked: t.Revoked, ExpiresAt: isoTime(t.ExpiresAt),
+			}
+		}
+
+		projectDeployTokens, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.DeployToken, *gitlab.Response, error) {
+			return gitClient.DeployTokens.ListProjectDeployTokens(p.ID, &gitlab.ListProjectDeployTokensOptions{Page: page, PerPage: perPage}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deploy tokens of project %s: %w", p.PathWithNamespace, err)
+		}
+		for _, t := range projectDeployTokens {
+			deployOwners[t.ID] = owner
+		}
+	}
+
+	inventory := make([]AccessToken, 0, len(tokens))
+	for _, t := range tokens {
+		if t.Revoked && !inv.cfg.IncludeRevoked {
+			continue
+		}
+		inventory = append(inventory, t)
+	}
+
+	// Deploy tokens have their own ID space
+	deployTokens, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.DeployToken, *gitlab.Response, error) {
+		options = append(options, withQuery("page", strconv.Itoa(page)), withQuery("per_page", strconv.Itoa(perPage)))
+		if !inv.cfg.IncludeRevoked {
+			options = append(options, withQuery("active", "true"))
+		}
+		return gitClient.DeployTokens.ListAllDeployTokens(options...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deploy tokens: %w", err)
+	}
+	for _, t := range deployTokens {
+		if t.Revoked && !inv.cfg.IncludeRevoked {
+			continue
+		}
+		inventory = append(inventory, AccessToken{
+			ID: t.ID, Type: tokenTypeDeploy, Name: t.Name, Owner: deployOwners[t.ID],
+			Scopes: t.Scopes, Active: !t.Revoked && !t.Expired, Revoked: t.Revoked, ExpiresAt: t.ExpiresAt,
+		})
+	}
+
+	return &source.Result{Details: inventory}, nil
+}
+
+// isoTime converts an optional GitLab date to a time
+func isoTime(t *gitlab.ISOTime) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := time.Time(*t)
+	return &converted
+}
+
+// inventoryMetrics holds the Prometheus descriptors of the token inventory for one instance
+type inventoryMetrics struct {
+	info            *prometheus.Desc
+	active          *prometheus.Desc
+	expiresAt       *prometheus.Desc
+	daysUntilExpiry *prometheus.Desc
+	tokens          *prometheus.Desc
+}
+
+// newInventoryMetrics creates the token inventory descriptors with the instance as a constant label
+func newInventoryMetrics(instance string) inventoryMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	tokenLabels := []string{"token_id", "type"}
+	return inventoryMetrics{
+		info: prometheus.NewDesc(
+			"gitlab_access_token_info",
+			"GitLab access token with its name, owner, scopes and state",
+			[]string{"token_id", "type", "name", "owner", "scopes", "state"},
+			constLabels,
+		),
+		active: prometheus.NewDesc(
+			"gitlab_access_token_active",
+			"Whether the GitLab access token can be used",
+			tokenLabels,
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"gitlab_access_token_expiry_timestamp_seconds",
+			"Unix timestamp when the GitLab access token expires, absent for tokens without expiry",
+			tokenLabels,
+			constLabels,
+		),
+		daysUntilExpiry: prometheus.NewDesc(
+			"gitlab_access_token_days_until_expiry",
+			"Days until the GitLab access token expires, negative once expired",
+			tokenLabels,
+			constLabels,
+		),
+		tokens: prometheus.NewDesc(
+			"gitlab_access_tokens",
+			"GitLab access tokens by type and state",
+			[]string{"type", "state"},
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the token inventory metric descriptors.
+func (inv *TokenInventory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- inv.metrics.info
+	ch <- inv.metrics.active
+	ch <- inv.metrics.expiresAt
+	ch <- inv.metrics.daysUntilExpiry
+	ch <- inv.metrics.tokens
+	inv.cache.Describe(ch)
+}
+
+// Collect lists the tokens through the cache and sends them as Prometheus metrics.
+func (inv *TokenInventory) Collect(ch chan<- prometheus.Metric) {
+	result, err := inv.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to list GitLab access tokens for instance %s: %v", inv.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	type typeState struct{ tokenType, state string }
+	counts := make(map[typeState]int)
+	for _, t := range result.Details.([]AccessToken) {
+		id := strconv.Itoa(t.ID)
+		scopes := append([]string(nil), t.Scopes...)
+		sort.Strings(scopes)
+		counts[typeState{t.Type, t.State()}]++
+
+		ch <- prometheus.MustNewConstMetric(inv.metrics.info, prometheus.GaugeValue, 1,
+			id, t.Type, t.Name, t.Owner, strings.Join(scopes, ","), t.State())
+		ch <- prometheus.MustNewConstMetric(inv.metrics.active, prometheus.GaugeValue, boolValue(t.Active), id, t.Type)
+
+		// Days until expiry are computed against the current time, not the fetch time
+		if t.ExpiresAt != nil {
+			ch <- prometheus.MustNewConstMetric(inv.metrics.expiresAt, prometheus.GaugeValue, float64(t.ExpiresAt.Unix()), id, t.Type)
+			ch <- prometheus.MustNewConstMetric(inv.metrics.daysUntilExpiry, prometheus.GaugeValue, float64(int(time.Until(*t.ExpiresAt).Hours()/24)), id, t.Type)
+		}
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(inv.metrics.tokens, prometheus.GaugeValue, float64(count), key.tokenType, key.state)
+	}
+}
+
+// boolValue converts a bool to a gauge value
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
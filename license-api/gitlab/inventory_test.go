@@ -0,0 +1,73 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+)
+
+func TestTokenInventoryOwners(t *testing.T) {
+	token := func(id int, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": name, "active": true, "scopes": []string{"read_api"}}
+	}
+	responses := map[string]interface{}{
+		"personal_access_tokens": []interface{}{
+			map[string]interface{}{"id": 1, "name": "admin", "user_id": 7, "active": true},
+			map[string]interface{}{"id": 2, "name": "group bot", "user_id": 8, "active": true},
+		},
+		"groups/42":                    map[string]interface{}{"id": 42, "full_path": "devsecops"},
+		"groups/42/access_tokens":      []interface{}{token(2, "group bot")},
+		"groups/42/deploy_tokens":      []interface{}{token(10, "registry")},
+		"projects/devsecops/pipelines": map[string]interface{}{"id": 99, "path_with_namespace": "devsecops/pipelines"},
+		"projects/99/access_tokens":    []interface{}{token(3, "project bot")},
+		"projects/99/deploy_tokens":    []interface{}{token(11, "deploy")},
+		"deploy_tokens":                []interface{}{token(10, "registry"), token(11, "deploy"), token(12, "elsewhere")},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Paths are looked up decoded, so a project path is found however it was escaped
+		response, ok := responses[strings.TrimPrefix(r.URL.Path, "/api/v4/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	cfg := config.GitLab{Name: "test", URL: server.URL + "/api/v4"}
+	s := NewSource(cfg, nil, audit.New(""), time.Minute, 5*time.Second)
+	gitClient, err := CreateGitLabClient(cfg, "glpat-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.setClient(gitClient, &Token{Token: "glpat-admin"})
+
+	inv := NewTokenInventory(s, config.TokenInventory{Groups: []string{"42"}, Projects: []string{"devsecops/pipelines"}})
+	result, err := inv.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	owners := make(map[string]string)
+	for _, token := range result.Details.([]AccessToken) {
+		owners[token.Type+" "+token.Name] = token.Owner
+	}
+	want := map[string]string{
+		"personal admin":      "user/7",
+		"group group bot":     "group/devsecops",
+		"project project bot": "project/devsecops/pipelines",
+		"deploy registry":     "group/devsecops",
+		"deploy deploy":       "project/devsecops/pipelines",
+		"deploy elsewhere":    "",
+	}
+	if !reflect.DeepEqual(owners, want) {
+		t.Errorf("token owners = %v, want %v", owners, want)
+	}
+}
@@ -0,0 +1,154 @@
+package gitlab
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/source"
+)
+
+// licenseKey holds the terms of a GitLab license key
+type licenseKey struct {
+	StartsAt     string `json:"starts_at"`
+	IssuedAt     string `json:"issued_at"`
+	ExpiresAt    string `json:"expires_at"`
+	Restrictions struct {
+		Plan            string `json:"plan"`
+		ActiveUserCount int    `json:"active_user_count"`
+	} `json:"restrictions"`
+}
+
+// expiry returns the expiry of the key, zero when it has none
+func (k *licenseKey) expiry() time.Time {
+	expiresAt, _ := time.Parse("2006-01-02", k.ExpiresAt)
+	return expiresAt
+}
+
+// startsAt returns the start date of the key, older keys only have an issue date
+func (k *licenseKey) startsAt() string {
+	if k.StartsAt != "" {
+		return k.StartsAt
+	}
+	return k.IssuedAt
+}
+
+// planRanks orders the GitLab plans, including their former names, to detect plan downgrades
+var planRanks = map[string]int{
+	"starter": 1, "bronze": 1,
+	"premium": 2, "silver": 2,
+	"ultimate": 3, "gold": 3,
+}
+
+// readLicensePublicKey reads the RSA public key GitLab decodes license keys with from a PEM file
+func readLicensePublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	var key interface{}
+	if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA public key", path)
+	}
+	return publicKey, nil
+}
+
+// decodeLicenseKey decodes a .gitlab-license key the way GitLab does on upload: the base64 JSON envelope
+// holds the license AES-128-CBC encrypted with a key that is itself encrypted with the private key of
+// the license issuer.
+func decodeLicenseKey(key []byte, publicKey *rsa.PublicKey) (*licenseKey, error) {
+	envelope, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(key)), ""))
+	if err != nil {
+		return nil, source.WithReason(source.ReasonDecode, fmt.Errorf("license key is not base64: %w", err))
+	}
+	var encrypted struct {
+		Data string `json:"data"`
+		Key  string `json:"key"`
+		IV   string `json:"iv"`
+	}
+	if err := json.Unmarshal(envelope, &encrypted); err != nil {
+		return nil, source.WithReason(source.ReasonDecode, fmt.Errorf("license key is not a GitLab license: %w", err))
+	}
+	var data, encryptedKey, iv []byte
+	for _, field := range []struct {
+		value string
+		to    *[]byte
+	}{{encrypted.Data, &data}, {encrypted.Key, &encryptedKey}, {encrypted.IV, &iv}} {
+		if *field.to, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(field.value), "")); err != nil {
+			return nil, source.WithReason(source.ReasonDecode, fmt.Errorf("license key is not a GitLab license: %w", err))
+		}
+	}
+
+	aesKey, err := publicDecrypt(publicKey, encryptedKey)
+	if err != nil {
+		return nil, source.WithReason(source.ReasonDecode, fmt.Errorf("license key was not issued for the configured public key: %w", err))
+	}
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, source.WithReason(source.ReasonDecode, err)
+	}
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, source.WithReason(source.ReasonDecode, errors.New("license key data is not AES-CBC encrypted"))
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	if plain, err = unpad(plain, block.BlockSize()); err != nil {
+		return nil, source.WithReason(source.ReasonDecode, err)
+	}
+
+	var license licenseKey
+	if err := json.Unmarshal(plain, &license); err != nil {
+		return nil, source.WithReason(source.ReasonDecode, fmt.Errorf("failed to decode license: %w", err))
+	}
+	return &license, nil
+}
+
+// publicDecrypt reverses the RSA private key encryption with PKCS #1 v1.5 padding used for the AES key
+func publicDecrypt(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+	size := publicKey.Size()
+	c := new(big.Int).SetBytes(data)
+	if len(data) != size || c.Cmp(publicKey.N) >= 0 {
+		return nil, errors.New("invalid key size")
+	}
+	m := new(big.Int).Exp(c, big.NewInt(int64(publicKey.E)), publicKey.N).FillBytes(make([]byte, size))
+
+	// Block type 1: 0x00 0x01, at least 8 0xff bytes, 0x00, then the data
+	if m[0] != 0 || m[1] != 1 {
+		return nil, errors.New("invalid padding")
+	}
+	end := bytes.IndexByte(m[2:], 0)
+	if end < 8 || len(bytes.Trim(m[2:2+end], "\xff")) != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return m[3+end:], nil
+}
+
+// unpad removes the PKCS #7 padding of decrypted data
+func unpad(data []byte, blockSize int) ([]byte, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || !bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("license key data has invalid padding")
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,243 @@
+package gitlab
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+)
+
+// encodeLicenseKey encodes a license the way the license issuer does, encrypting its AES key with privateKey
+func encodeLicenseKey(t *testing.T, privateKey *rsa.PrivateKey, license map[string]interface{}) []byte {
+	t.Helper()
+	plain, err := json.Marshal(license)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesKey, iv := make([]byte, 16), make([]byte, aes.BlockSize)
+	rand.Read(aesKey)
+	rand.Read(iv)
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	for i := 0; i < padding; i++ {
+		plain = append(plain, byte(padding))
+	}
+	data := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, plain)
+
+	// Signing unhashed data applies the private key with PKCS #1 v1.5 block type 1, like Ruby's private_encrypt
+	encryptedKey, err := rsa.SignPKCS1v15(nil, privateKey, 0, aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := json.Marshal(map[string]string{
+		"data": base64.StdEncoding.EncodeToString(data),
+		"key":  base64.StdEncoding.EncodeToString(encryptedKey),
+		"iv":   base64.StdEncoding.EncodeToString(iv),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(envelope) + "\n")
+}
+
+// fakeLicenses serves the license upload and list API, decoding uploaded keys with publicKey
+type fakeLicenses struct {
+	mu        sync.Mutex
+	publicKey *rsa.PublicKey
+	licenses  []map[string]interface{}
+	uploads   int
+}
+
+func (f *fakeLicenses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v4/licenses":
+		json.NewEncoder(w).Encode(f.licenses)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v4/license":
+		body, _ := io.ReadAll(r.Body)
+		var upload struct {
+			License string `json:"license"`
+		}
+		json.Unmarshal(body, &upload)
+		key, err := decodeLicenseKey([]byte(upload.License), f.publicKey)
+		if err != nil {
+			http.Error(w, `{"message":"invalid license"}`, http.StatusBadRequest)
+			return
+		}
+		f.uploads++
+		license := map[string]interface{}{
+			"id": len(f.licenses) + 1, "plan": key.Restrictions.Plan, "starts_at": key.startsAt(),
+			"expires_at": key.ExpiresAt, "user_limit": key.Restrictions.ActiveUserCount,
+		}
+		f.licenses = append(f.licenses, license)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(license)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestApplyLicense(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyFile := filepath.Join(t.TempDir(), "license.pub")
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	license := func(plan, startsAt, expiresAt string, users int) map[string]interface{} {
+		return map[string]interface{}{
+			"version": 1, "starts_at": startsAt, "expires_at": expiresAt,
+			"restrictions": map[string]interface{}{"plan": plan, "active_user_count": users},
+		}
+	}
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	current := &source.Result{Plan: "premium", ExpiresAt: date("2026-12-31"), Quantity: 500}
+	uploaded := license("premium", "2026-01-01", "2026-12-31", 500)
+
+	tests := []struct {
+		name        string
+		key         []byte
+		declared    *source.Result
+		current     *source.Result
+		noPublicKey bool
+		wantReason  string
+		wantUpload  bool
+	}{
+		{
+			name:       "later expiry is uploaded",
+			key:        encodeLicenseKey(t, privateKey, license("premium", "2027-01-01", "2027-12-31", 500)),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31"), Quantity: 500},
+			current:    current,
+			wantUpload: true,
+		},
+		{
+			name:       "more users on the same term are uploaded",
+			key:        encodeLicenseKey(t, privateKey, license("premium", "2026-01-01", "2026-12-31", 600)),
+			declared:   &source.Result{ExpiresAt: date("2026-12-31"), Quantity: 600},
+			current:    current,
+			wantUpload: true,
+		},
+		{
+			name:       "key is installed without a current license",
+			key:        encodeLicenseKey(t, privateKey, license("ultimate", "2026-01-01", "2026-06-30", 100)),
+			declared:   &source.Result{ExpiresAt: date("2026-06-30")},
+			wantUpload: true,
+		},
+		{
+			name:       "earlier expiry is refused",
+			key:        encodeLicenseKey(t, privateKey, license("premium", "2026-01-01", "2026-06-30", 500)),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31")},
+			current:    current,
+			wantReason: source.ReasonDowngrade,
+		},
+		{
+			name:       "lower plan is refused",
+			key:        encodeLicenseKey(t, privateKey, license("starter", "2027-01-01", "2027-12-31", 500)),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31")},
+			current:    current,
+			wantReason: source.ReasonDowngrade,
+		},
+		{
+			name:       "terms other than declared are refused",
+			key:        encodeLicenseKey(t, privateKey, license("premium", "2027-01-01", "2027-12-31", 400)),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31"), Quantity: 500},
+			current:    &source.Result{Plan: "premium", ExpiresAt: date("2026-12-31"), Quantity: 300},
+			wantReason: source.ReasonMismatch,
+		},
+		{
+			name:     "already uploaded key is not uploaded again",
+			key:      encodeLicenseKey(t, privateKey, uploaded),
+			declared: &source.Result{ExpiresAt: date("2026-12-31"), Quantity: 500},
+			current:  &source.Result{Plan: "premium", ExpiresAt: date("2026-06-30"), Quantity: 500},
+		},
+		{
+			name:       "key of another issuer is refused",
+			key:        encodeLicenseKey(t, otherKey, license("premium", "2027-01-01", "2027-12-31", 500)),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31")},
+			current:    current,
+			wantReason: source.ReasonDecode,
+		},
+		{
+			name:       "key is not a license",
+			key:        []byte("not a license"),
+			declared:   &source.Result{ExpiresAt: date("2027-12-31")},
+			current:    current,
+			wantReason: source.ReasonDecode,
+		},
+		{
+			name:        "no public key",
+			key:         encodeLicenseKey(t, privateKey, license("premium", "2027-01-01", "2027-12-31", 500)),
+			declared:    &source.Result{ExpiresAt: date("2027-12-31")},
+			current:     current,
+			noPublicKey: true,
+			wantReason:  source.ReasonUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLicenses{publicKey: &privateKey.PublicKey, licenses: []map[string]interface{}{
+				{"id": 1, "plan": "premium", "starts_at": "2026-01-01", "expires_at": "2026-12-31", "user_limit": 500},
+			}}
+			server := httptest.NewServer(f)
+			t.Cleanup(server.Close)
+
+			cfg := config.GitLab{Name: "test", URL: server.URL + "/api/v4", LicensePublicKeyFile: publicKeyFile}
+			if tt.noPublicKey {
+				cfg.LicensePublicKeyFile = ""
+			}
+			s := NewSource(cfg, nil, audit.New(""), time.Minute, 5*time.Second)
+			gitClient, err := CreateGitLabClient(cfg, "glpat-admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.setClient(gitClient, &Token{Token: "glpat-admin"})
+
+			err = s.ApplyLicense(context.Background(), tt.key, tt.declared, tt.current)
+			if tt.wantReason == "" && err != nil {
+				t.Fatalf("ApplyLicense() error = %v", err)
+			}
+			if tt.wantReason != "" && (err == nil || source.ReasonOf(err) != tt.wantReason) {
+				t.Fatalf("ApplyLicense() error = %v, want reason %s", err, tt.wantReason)
+			}
+			if uploaded := f.uploads > 0; uploaded != tt.wantUpload {
+				t.Errorf("key uploaded = %v, want %v", uploaded, tt.wantUpload)
+			}
+		})
+	}
+}
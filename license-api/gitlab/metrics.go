@@ -2,6 +2,7 @@ package gitlab
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -34,35 +35,256 @@ type Licensee struct {
 	Company string `json:"Company"`
 }
 
-// AddOns represents the add-ons information
-type AddOns struct{}
+// AddOns maps the add-on codes of a license, e.g. GitLab_Auditor_User, to their quantity
+type AddOns map[string]int
 
-// Prometheus metrics
+// Prometheus metrics of the access token rotation, set by RunRotation
 var (
-	licenseMetric = prometheus.NewGaugeVec(
+	patDaysUntilExpiryMetric = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: "gitlab_license",
-			Help: "License information from GitLab",
+			Name: "gitlab_pat_days_until_expiry",
+			Help: "Days until the GitLab access token used by the exporter expires",
 		},
-		[]string{"plan", "created_at", "starts_at", "expires_at", "historical_max", "maximum_user_count", "licensee_name", "licensee_email", "licensee_company", "add_ons", "expired", "overage", "user_limit", "active_users", "days_until_expiry", "remaining_users"},
+		[]string{"instance"},
 	)
+	lastRotationMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gitlab_pat_last_rotation_timestamp_seconds",
+			Help: "Unix timestamp of the last successful GitLab access token rotation",
+		},
+		[]string{"instance"},
+	)
+	rotationFailuresMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gitlab_pat_rotation_failures_total",
+			Help: "Failed GitLab access token rotations and expiry checks",
+		},
+		[]string{"instance"},
+	)
+)
 
-	daysUntilExpiryMetric = prometheus.NewGauge(
+// Prometheus metrics of the seat reclamation, set by RunReclaim
+var (
+	deactivatedUsersMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gitlab_reclaim_deactivated_users_total",
+			Help: "Inactive GitLab users deactivated by the exporter",
+		},
+		[]string{"instance"},
+	)
+	reclaimFailuresMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gitlab_reclaim_failures_total",
+			Help: "Failed seat reclamation runs and user deactivations",
+		},
+		[]string{"instance"},
+	)
+	reclaimCandidatesMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gitlab_reclaim_last_run_candidates",
+			Help: "Users selected for deactivation by the last reclamation run, including dry runs",
+		},
+		[]string{"instance"},
+	)
+	reclaimLastRunMetric = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: "gitlab_license_days_until_expiry",
-			Help: "Days until Gitlab License expires",
+			Name: "gitlab_reclaim_last_run_timestamp_seconds",
+			Help: "Unix timestamp of the last completed seat reclamation run",
 		},
+		[]string{"instance"},
 	)
 )
 
 func init() {
-	// Register the custom metrics with Prometheus's default registry
-	prometheus.MustRegister(licenseMetric)
-	prometheus.MustRegister(daysUntilExpiryMetric)
+	// Register metrics with Prometheus
+	prometheus.MustRegister(patDaysUntilExpiryMetric)
+	prometheus.MustRegister(lastRotationMetric)
+	prometheus.MustRegister(rotationFailuresMetric)
+	prometheus.MustRegister(deactivatedUsersMetric)
+	prometheus.MustRegister(reclaimFailuresMetric)
+	prometheus.MustRegister(reclaimCandidatesMetric)
+	prometheus.MustRegister(reclaimLastRunMetric)
+}
+
+// metrics holds the Prometheus descriptors of the GitLab license metrics for one instance
+type metrics struct {
+	license          *prometheus.Desc
+	legacyLicense    *prometheus.Desc
+	activeUsers      *prometheus.Desc
+	userLimit        *prometheus.Desc
+	overage          *prometheus.Desc
+	historicalMax    *prometheus.Desc
+	maximumUserCount *prometheus.Desc
+	remainingUsers   *prometheus.Desc
+	daysUntilExpiry  *prometheus.Desc
+	expiresAt        *prometheus.Desc
+	startsAt         *prometheus.Desc
+	addOnQuantity    *prometheus.Desc
+	cost             costMetrics
+}
+
+// newMetrics creates the metric descriptors with the instance as a constant label
+func newMetrics(instance string) metrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return metrics{
+		license: prometheus.NewDesc(
+			"gitlab_license_info",
+			"License information from GitLab, labelled with the stable license identity",
+			[]string{"license_id", "plan", "licensee_company"},
+			constLabels,
+		),
+		legacyLicense: prometheus.NewDesc(
+			"gitlab_license",
+			"Deprecated, use gitlab_license_info and the gitlab_license_* gauges. License information from GitLab",
+			[]string{"plan", "created_at", "starts_at", "expires_at", "historical_max", "maximum_user_count", "licensee_name", "licensee_email", "licensee_company", "add_ons", "expired", "overage", "user_limit", "active_users", "days_until_expiry", "remaining_users"},
+			constLabels,
+		),
+		activeUsers: prometheus.NewDesc(
+			"gitlab_license_active_users",
+			"Active users counted against the Gitlab License",
+			nil,
+			constLabels,
+		),
+		userLimit: prometheus.NewDesc(
+			"gitlab_license_user_limit",
+			"Users allowed by the Gitlab License",
+			nil,
+			constLabels,
+		),
+		overage: prometheus.NewDesc(
+			"gitlab_license_overage",
+			"Users above the Gitlab License user limit",
+			nil,
+			constLabels,
+		),
+		historicalMax: prometheus.NewDesc(
+			"gitlab_license_historical_max",
+			"Highest number of active users during the Gitlab License term",
+			nil,
+			constLabels,
+		),
+		maximumUserCount: prometheus.NewDesc(
+			"gitlab_license_maximum_user_count",
+			"Maximum user count reported by the Gitlab License",
+			nil,
+			constLabels,
+		),
+		remainingUsers: prometheus.NewDesc(
+			"gitlab_license_remaining_users",
+			"Users that can still be added before reaching the Gitlab License user limit",
+			nil,
+			constLabels,
+		),
+		daysUntilExpiry: prometheus.NewDesc(
+			"gitlab_license_days_until_expiry",
+			"Days until Gitlab License expires",
+			nil,
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"gitlab_license_expiry_timestamp_seconds",
+			"Unix timestamp when the Gitlab License expires",
+			nil,
+			constLabels,
+		),
+		startsAt: prometheus.NewDesc(
+			"gitlab_license_starts_at_timestamp_seconds",
+			"Unix timestamp when the Gitlab License starts",
+			nil,
+			constLabels,
+		),
+		addOnQuantity: prometheus.NewDesc(
+			"gitlab_license_add_on_quantity",
+			"Quantity of each add-on included in the Gitlab License, which expires with the license",
+			[]string{"add_on"},
+			constLabels,
+		),
+		cost: newCostMetrics(instance),
+	}
+}
+
+// Describe sends the GitLab license metric descriptors.
+func (s *Source) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.metrics.license
+	ch <- s.metrics.legacyLicense
+	ch <- s.metrics.activeUsers
+	ch <- s.metrics.userLimit
+	ch <- s.metrics.overage
+	ch <- s.metrics.historicalMax
+	ch <- s.metrics.maximumUserCount
+	ch <- s.metrics.remainingUsers
+	ch <- s.metrics.daysUntilExpiry
+	ch <- s.metrics.expiresAt
+	ch <- s.metrics.startsAt
+	ch <- s.metrics.addOnQuantity
+	s.metrics.cost.describe(ch)
+	s.cache.Describe(ch)
+}
+
+// Collect fetches the license through the cache and sends it as Prometheus metrics.
+func (s *Source) Collect(ch chan<- prometheus.Metric) {
+	result, err := s.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch GitLab license for instance %s: %v", s.cfg.Name, err)
+	}
+	if result == nil {
+		return
+	}
+
+	// Days until expiry are computed against the current time, not the fetch time
+	response := result.Details.(*licenseResponse)
+	license := NewLicense(response.License, response.AddOns)
+
+	// Only stable identity labels go on the info metric, numbers are exported as their own gauges
+	ch <- prometheus.MustNewConstMetric(s.metrics.license, prometheus.GaugeValue, 1,
+		fmt.Sprint(license.ID),
+		license.Plan,
+		license.Licensee.Company,
+	)
+	if s.cfg.LegacyLicenseMetric {
+		ch <- prometheus.MustNewConstMetric(s.metrics.legacyLicense, prometheus.GaugeValue, 1,
+			license.Plan,
+			fmt.Sprint(license.CreatedAt),
+			isoString(license.StartsAt),
+			isoString(license.ExpiresAt),
+			fmt.Sprint(license.HistoricalMax),
+			fmt.Sprint(license.MaximumUserCount),
+			license.Licensee.Name,
+			license.Licensee.Email,
+			license.Licensee.Company,
+			fmt.Sprint(license.AddOns),
+			fmt.Sprint(license.Expired),
+			fmt.Sprint(license.Overage),
+			fmt.Sprint(license.UserLimit),
+			fmt.Sprint(license.ActiveUsers),
+			fmt.Sprint(license.DaysUntilExpiry),
+			fmt.Sprint(license.RemainingUsers),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(s.metrics.activeUsers, prometheus.GaugeValue, float64(license.ActiveUsers))
+	ch <- prometheus.MustNewConstMetric(s.metrics.userLimit, prometheus.GaugeValue, float64(license.UserLimit))
+	ch <- prometheus.MustNewConstMetric(s.metrics.overage, prometheus.GaugeValue, float64(license.Overage))
+	ch <- prometheus.MustNewConstMetric(s.metrics.historicalMax, prometheus.GaugeValue, float64(license.HistoricalMax))
+	ch <- prometheus.MustNewConstMetric(s.metrics.maximumUserCount, prometheus.GaugeValue, float64(license.MaximumUserCount))
+	ch <- prometheus.MustNewConstMetric(s.metrics.remainingUsers, prometheus.GaugeValue, float64(license.RemainingUsers))
+	ch <- prometheus.MustNewConstMetric(s.metrics.daysUntilExpiry, prometheus.GaugeValue, float64(license.DaysUntilExpiry))
+
+	// Timestamps let PromQL compute the exact time left, including negative values once expired
+	if license.ExpiresAt != nil {
+		ch <- prometheus.MustNewConstMetric(s.metrics.expiresAt, prometheus.GaugeValue, float64(time.Time(*license.ExpiresAt).Unix()))
+	}
+	if license.StartsAt != nil {
+		ch <- prometheus.MustNewConstMetric(s.metrics.startsAt, prometheus.GaugeValue, float64(time.Time(*license.StartsAt).Unix()))
+	}
+	for addOn, quantity := range license.AddOns {
+		ch <- prometheus.MustNewConstMetric(s.metrics.addOnQuantity, prometheus.GaugeValue, float64(quantity), addOn)
+	}
+
+	s.metrics.cost.collect(ch, s.cfg.Cost, license)
 }
 
 // func NewLicense recreates License struct to add additional label daysUntilExpiration and convert ISOTime to time.Time
-func NewLicense(license *gitlab.License) License {
+func NewLicense(license *gitlab.License, addOns AddOns) License {
 	expirationTime := time.Time(*license.ExpiresAt) // Convert ISOTime to time.Time
 	daysUntilExpiration := 0
 
@@ -79,7 +301,7 @@ func NewLicense(license *gitlab.License) License {
 		HistoricalMax:    license.HistoricalMax,
 		MaximumUserCount: license.MaximumUserCount,
 		Licensee:         Licensee{Name: license.Licensee.Name, Email: license.Licensee.Email, Company: license.Licensee.Company},
-		AddOns:           AddOns{},
+		AddOns:           addOns,
 		Expired:          license.Expired,
 		Overage:          license.Overage,
 		UserLimit:        license.UserLimit,
@@ -88,27 +310,3 @@ func NewLicense(license *gitlab.License) License {
 		RemainingUsers:   license.UserLimit - license.ActiveUsers,
 	}
 }
-
-// RegisterMetrics registers license information as Prometheus metrics.
-func RegisterMetrics(license License) {
-	licenseMetric.With(prometheus.Labels{
-		"plan":               license.Plan,
-		"created_at":         license.CreatedAt.String(),
-		"starts_at":          license.StartsAt.String(),
-		"expires_at":         license.ExpiresAt.String(),
-		"historical_max":     fmt.Sprint(license.HistoricalMax),
-		"maximum_user_count": fmt.Sprint(license.MaximumUserCount),
-		"licensee_name":      license.Licensee.Name,
-		"licensee_email":     license.Licensee.Email,
-		"licensee_company":   license.Licensee.Company,
-		"add_ons":            fmt.Sprint(license.AddOns),
-		"expired":            fmt.Sprint(license.Expired),
-		"overage":            fmt.Sprint(license.Overage),
-		"user_limit":         fmt.Sprint(license.UserLimit),
-		"active_users":       fmt.Sprint(license.ActiveUsers),
-		"days_until_expiry":  fmt.Sprint(license.DaysUntilExpiry),
-		"remaining_users":    fmt.Sprint(license.RemainingUsers),
-	}).Set(1)
-
-	daysUntilExpiryMetric.Set(float64(license.DaysUntilExpiry))
-}
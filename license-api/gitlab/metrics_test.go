@@ -0,0 +1,54 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLegacyLicenseMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/license" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": 7, "plan": "premium", "starts_at": "2026-01-01", "expires_at": "2027-01-01",
+			"user_limit": 500, "active_users": 420, "licensee": map[string]string{"Company": "Example"},
+		})
+	}))
+	defer server.Close()
+
+	for _, legacy := range []bool{true, false} {
+		cfg := config.GitLab{Name: "test", URL: server.URL + "/api/v4", LegacyLicenseMetric: legacy}
+		s := NewSource(cfg, nil, audit.New(""), time.Minute, 5*time.Second)
+		gitClient, err := CreateGitLabClient(cfg, "glpat-admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.setClient(gitClient, &Token{Token: "glpat-admin"})
+
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(s)
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make(map[string]bool)
+		for _, family := range families {
+			names[family.GetName()] = true
+		}
+		if !names["gitlab_license_info"] || !names["gitlab_license_active_users"] {
+			t.Errorf("legacy_license_metric %v: gitlab_license_info and gitlab_license_active_users not exported", legacy)
+		}
+		if names["gitlab_license"] != legacy {
+			t.Errorf("legacy_license_metric %v: gitlab_license exported = %v", legacy, names["gitlab_license"])
+		}
+	}
+}
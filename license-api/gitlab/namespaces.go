@@ -0,0 +1,390 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/sync/errgroup"
+)
+
+// namespaceUsageQuery reads the storage statistics and limits of a namespace and its monthly CI/CD minute usage
+const namespaceUsageQuery = `query($fullPath: ID!, $namespaceId: NamespaceID) {
+  namespace(fullPath: $fullPath) {
+    actualRepositorySizeLimit
+    storageSizeLimit
+    additionalPurchasedStorageSize
+    rootStorageStatistics {
+      storageSize
+      repositorySize
+      lfsObjectsSize
+      packagesSize
+      containerRegistrySize
+    }
+  }
+  ciMinutesUsage(namespaceId: $namespaceId) {
+    nodes {
+      monthIso8601
+      minutes
+    }
+  }
+}`
+
+// namespace is a namespace as returned by the admin API, with the CI/CD minute quota the client does not decode
+type namespace struct {
+	ID                             int    `json:"id"`
+	FullPath                       string `json:"full_path"`
+	Kind                           string `json:"kind"`
+	ParentID                       int    `json:"parent_id"`
+	SharedRunnersMinutesLimit      *int   `json:"shared_runners_minutes_limit"`
+	ExtraSharedRunnersMinutesLimit *int   `json:"extra_shared_runners_minutes_limit"`
+}
+
+// NamespaceUsage is the storage and CI/CD minute usage of one top-level namespace. Limits and
+// quotas are zero when unlimited, Storage is nil until GitLab has computed the statistics.
+type NamespaceUsage struct {
+	FullPath string
+	// Storage holds the bytes used by storage type, e.g. "repository" or "container_registry", and "total"
+	Storage             map[string]float64
+	StorageLimit        float64
+	RepositorySizeLimit float64
+	CIMinutesUsed       float64
+	CIMinutesQuota      float64
+	CIMinutesExtraQuota float64
+}
+
+// NamespaceQuotas exports the storage and shared runner CI/CD minute usage of the top-level namespaces
+// of a GitLab instance against their limits with the client of its license Source. It needs an admin token.
+type NamespaceQuotas struct {
+	source  *Source
+	cfg     config.NamespaceQuotas
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	cache   *source.Cache
+	metrics namespaceMetrics
+}
+
+// NewNamespaceQuotas returns the namespace quota collector of the instance of s, the filters are checked when the config is loaded
+func NewNamespaceQuotas(s *Source, cfg config.NamespaceQuotas) *NamespaceQuotas {
+	n := &NamespaceQuotas{
+		source:  s,
+		cfg:     cfg,
+		include: regexp.MustCompile(cfg.Include),
+		metrics: newNamespaceMetrics(s.Instance()),
+	}
+	if cfg.Exclude != "" {
+		n.exclude = regexp.MustCompile(cfg.Exclude)
+	}
+	n.cache = source.NewCache(n, cfg.CacheTTL, cfg.FetchTimeout)
+	return n
+}
+
+// Name returns the name of the collector.
+func (n *NamespaceQuotas) Name() string {
+	return "gitlab_namespace_quotas"
+}
+
+// Instance returns the configured instance name of the collector.
+func (n *NamespaceQuotas) Instance() string {
+	return n.source.Instance()
+}
+
+// Fetch reads the usage of the watched namespaces, Details holds a NamespaceUsage for each namespace
+// read. It only fails when no namespace could be read.
+func (n *NamespaceQuotas) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := n.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := n.listNamespaces(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// Namespaces without their own minute quota use the instance default
+	var defaultMinutes int
+	for _, ns := range namespaces {
+		if ns.SharedRunnersMinutesLimit == nil {
+			settings, _, err := gitClient.Settings.GetSettings(gitlab.WithContext(ctx))
+			if err != nil {
+				return nil, apiError(fmt.Errorf("failed to get application settings: %w", err))
+			}
+			defaultMinutes = settings.SharedRunnersMinutes
+			break
+		}
+	}
+
+	usage := make([]NamespaceUsage, len(namespaces))
+	errs := make([]error, len(namespaces))
+	month := time.Now().UTC().Format("2006-01")
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(n.cfg.Concurrency)
+	for i, ns := range namespaces {
+		group.Go(func() error {
+			nsCtx, cancel := context.WithTimeout(groupCtx, n.cfg.NamespaceTimeout)
+			defer cancel()
+			u, err := namespaceUsage(nsCtx, gitClient, ns, month)
+			if err != nil {
+				errs[i] = err
+				return nil
+			}
+			u.CIMinutesQuota = float64(defaultMinutes)
+			if ns.SharedRunnersMinutesLimit != nil {
+				u.CIMinutesQuota = float64(*ns.SharedRunnersMinutesLimit)
+			}
+			if ns.ExtraSharedRunnersMinutesLimit != nil {
+				u.CIMinutesExtraQuota = float64(*ns.ExtraSharedRunnersMinutesLimit)
+			}
+			usage[i] = u
+			return nil
+		})
+	}
+	group.Wait()
+
+	// A namespace that fails is left out, the others are still exported
+	var read []NamespaceUsage
+	var failed []error
+	for i := range namespaces {
+		if errs[i] != nil {
+			log.Printf("Failed to fetch GitLab namespace quotas for instance %s: %v", n.Instance(), errs[i])
+			failed = append(failed, errs[i])
+			continue
+		}
+		read = append(read, usage[i])
+	}
+	if len(read) == 0 && len(failed) > 0 {
+		return nil, errors.Join(failed...)
+	}
+	return &source.Result{Details: read}, nil
+}
+
+// listNamespaces returns the configured namespaces, or the top-level namespaces passing the filters,
+// sorted by path and capped at MaxNamespaces. A configured ID and path, or an old path GitLab redirects,
+// may resolve to the same namespace, which is only returned once.
+func (n *NamespaceQuotas) listNamespaces(ctx context.Context, gitClient *gitlab.Client) ([]*namespace, error) {
+	var namespaces []*namespace
+	if len(n.cfg.Namespaces) > 0 {
+		seen := make(map[int]string, len(n.cfg.Namespaces))
+		for _, path := range n.cfg.Namespaces {
+			req, err := gitClient.NewRequest(http.MethodGet, "namespaces/"+gitlab.PathEscape(path), nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+			if err != nil {
+				return nil, err
+			}
+			var ns namespace
+			if _, err := gitClient.Do(req, &ns); err != nil {
+				return nil, apiError(fmt.Errorf("failed to get namespace %s: %w", path, err))
+			}
+			if ns.ParentID != 0 {
+				log.Printf("Skipping namespace %s of GitLab instance %s: %s is not a top-level namespace", path, n.Instance(), ns.FullPath)
+				continue
+			}
+			if first, ok := seen[ns.ID]; ok {
+				log.Printf("Skipping namespace %s of GitLab instance %s: it is %s, already watched as %s", path, n.Instance(), ns.FullPath, first)
+				continue
+			}
+			seen[ns.ID] = path
+			namespaces = append(namespaces, &ns)
+		}
+	} else {
+		all, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*namespace, *gitlab.Response, error) {
+			// top_level_only is ignored by GitLab versions before 16.8, nested namespaces are skipped below as well
+			req, err := gitClient.NewRequest(http.MethodGet, "namespaces", &gitlab.ListOptions{Page: page, PerPage: perPage}, append(options, withQuery("top_level_only", "true")))
+			if err != nil {
+				return nil, nil, err
+			}
+			var namespaces []*namespace
+			resp, err := gitClient.Do(req, &namespaces)
+			if err != nil {
+				return nil, resp, err
+			}
+			return namespaces, resp, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range all {
+			if ns.ParentID == 0 && n.watched(ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].FullPath < namespaces[j].FullPath })
+	if len(namespaces) > n.cfg.MaxNamespaces {
+		log.Printf("Exporting quotas of the first %d of %d namespaces of GitLab instance %s, raise max_namespaces or narrow the filters to export more",
+			n.cfg.MaxNamespaces, len(namespaces), n.Instance())
+		namespaces = namespaces[:n.cfg.MaxNamespaces]
+	}
+	return namespaces, nil
+}
+
+// watched reports whether a listed namespace passes the kind and path filters
+func (n *NamespaceQuotas) watched(ns *namespace) bool {
+	if ns.Kind == "user" && !n.cfg.IncludeUsers {
+		return false
+	}
+	return n.include.MatchString(ns.FullPath) && (n.exclude == nil || !n.exclude.MatchString(ns.FullPath))
+}
+
+// namespaceUsage reads the storage and the CI/CD minutes used in month (2006-01) by a namespace
+func namespaceUsage(ctx context.Context, gitClient *gitlab.Client, ns *namespace, month string) (NamespaceUsage, error) {
+	var data struct {
+		Namespace *struct {
+			ActualRepositorySizeLimit      *float64 `json:"actualRepositorySizeLimit"`
+			StorageSizeLimit               *float64 `json:"storageSizeLimit"`
+			AdditionalPurchasedStorageSize *float64 `json:"additionalPurchasedStorageSize"`
+			RootStorageStatistics          *struct {
+				StorageSize           float64 `json:"storageSize"`
+				RepositorySize        float64 `json:"repositorySize"`
+				LFSObjectsSize        float64 `json:"lfsObjectsSize"`
+				PackagesSize          float64 `json:"packagesSize"`
+				ContainerRegistrySize float64 `json:"containerRegistrySize"`
+			} `json:"rootStorageStatistics"`
+		} `json:"namespace"`
+		CIMinutesUsage struct {
+			Nodes []struct {
+				MonthISO8601 string  `json:"monthIso8601"`
+				Minutes      float64 `json:"minutes"`
+			} `json:"nodes"`
+		} `json:"ciMinutesUsage"`
+	}
+	variables := map[string]interface{}{
+		"fullPath":    ns.FullPath,
+		"namespaceId": fmt.Sprintf("gid://gitlab/Namespace/%d", ns.ID),
+	}
+	if err := graphQL(ctx, gitClient, namespaceUsageQuery, variables, &data); err != nil {
+		return NamespaceUsage{}, fmt.Errorf("failed to get usage of namespace %s: %w", ns.FullPath, err)
+	}
+	if data.Namespace == nil {
+		return NamespaceUsage{}, fmt.Errorf("failed to get usage of namespace %s: namespace not found", ns.FullPath)
+	}
+
+	usage := NamespaceUsage{FullPath: ns.FullPath}
+	if stats := data.Namespace.RootStorageStatistics; stats != nil {
+		usage.Storage = map[string]float64{
+			"total":              stats.StorageSize,
+			"repository":         stats.RepositorySize,
+			"lfs":                stats.LFSObjectsSize,
+			"packages":           stats.PackagesSize,
+			"container_registry": stats.ContainerRegistrySize,
+		}
+	}
+	// Purchased storage extends the namespace storage limit
+	if limit := data.Namespace.StorageSizeLimit; limit != nil && *limit > 0 {
+		usage.StorageLimit = *limit
+		if purchased := data.Namespace.AdditionalPurchasedStorageSize; purchased != nil {
+			usage.StorageLimit += *purchased
+		}
+	}
+	if limit := data.Namespace.ActualRepositorySizeLimit; limit != nil {
+		usage.RepositorySizeLimit = *limit
+	}
+	for _, node := range data.CIMinutesUsage.Nodes {
+		if len(node.MonthISO8601) >= len(month) && node.MonthISO8601[:len(month)] == month {
+			usage.CIMinutesUsed += node.Minutes
+		}
+	}
+	return usage, nil
+}
+
+// namespaceMetrics holds the Prometheus descriptors of the namespace quotas for one instance
+type namespaceMetrics struct {
+	storage             *prometheus.Desc
+	storageLimit        *prometheus.Desc
+	repositorySizeLimit *prometheus.Desc
+	ciMinutesUsed       *prometheus.Desc
+	ciMinutesQuota      *prometheus.Desc
+	ciMinutesExtraQuota *prometheus.Desc
+}
+
+// newNamespaceMetrics creates the namespace quota descriptors with the instance as a constant label
+func newNamespaceMetrics(instance string) namespaceMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return namespaceMetrics{
+		storage: prometheus.NewDesc(
+			"gitlab_namespace_storage_bytes",
+			"Storage used by the GitLab namespace by type, total includes types not broken out",
+			[]string{"namespace", "type"},
+			constLabels,
+		),
+		storageLimit: prometheus.NewDesc(
+			"gitlab_namespace_storage_limit_bytes",
+			"Storage limit of the GitLab namespace including purchased storage, absent when unlimited",
+			[]string{"namespace"},
+			constLabels,
+		),
+		repositorySizeLimit: prometheus.NewDesc(
+			"gitlab_namespace_repository_size_limit_bytes",
+			"Size limit applied to each repository of the GitLab namespace, absent when unlimited",
+			[]string{"namespace"},
+			constLabels,
+		),
+		ciMinutesUsed: prometheus.NewDesc(
+			"gitlab_namespace_ci_minutes_used",
+			"Shared runner CI/CD minutes used by the GitLab namespace in the current month",
+			[]string{"namespace"},
+			constLabels,
+		),
+		ciMinutesQuota: prometheus.NewDesc(
+			"gitlab_namespace_ci_minutes_quota",
+			"Monthly shared runner CI/CD minute quota of the GitLab namespace, absent when unlimited",
+			[]string{"namespace"},
+			constLabels,
+		),
+		ciMinutesExtraQuota: prometheus.NewDesc(
+			"gitlab_namespace_ci_minutes_extra_quota",
+			"Additional shared runner CI/CD minutes purchased for the GitLab namespace",
+			[]string{"namespace"},
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the namespace quota metric descriptors.
+func (n *NamespaceQuotas) Describe(ch chan<- *prometheus.Desc) {
+	ch <- n.metrics.storage
+	ch <- n.metrics.storageLimit
+	ch <- n.metrics.repositorySizeLimit
+	ch <- n.metrics.ciMinutesUsed
+	ch <- n.metrics.ciMinutesQuota
+	ch <- n.metrics.ciMinutesExtraQuota
+	n.cache.Describe(ch)
+}
+
+// Collect fetches the namespace usage through the cache and sends it as Prometheus metrics.
+func (n *NamespaceQuotas) Collect(ch chan<- prometheus.Metric) {
+	result, err := n.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch GitLab namespace quotas for instance %s: %v", n.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	for _, usage := range result.Details.([]NamespaceUsage) {
+		for storageType, bytes := range usage.Storage {
+			ch <- prometheus.MustNewConstMetric(n.metrics.storage, prometheus.GaugeValue, bytes, usage.FullPath, storageType)
+		}
+		if usage.StorageLimit > 0 {
+			ch <- prometheus.MustNewConstMetric(n.metrics.storageLimit, prometheus.GaugeValue, usage.StorageLimit, usage.FullPath)
+		}
+		if usage.RepositorySizeLimit > 0 {
+			ch <- prometheus.MustNewConstMetric(n.metrics.repositorySizeLimit, prometheus.GaugeValue, usage.RepositorySizeLimit, usage.FullPath)
+		}
+		ch <- prometheus.MustNewConstMetric(n.metrics.ciMinutesUsed, prometheus.GaugeValue, usage.CIMinutesUsed, usage.FullPath)
+		if usage.CIMinutesQuota > 0 {
+			ch <- prometheus.MustNewConstMetric(n.metrics.ciMinutesQuota, prometheus.GaugeValue, usage.CIMinutesQuota, usage.FullPath)
+		}
+		ch <- prometheus.MustNewConstMetric(n.metrics.ciMinutesExtraQuota, prometheus.GaugeValue, usage.CIMinutesExtraQuota, usage.FullPath)
+	}
+}
@@ -0,0 +1,124 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+)
+
+// fakeNamespaces serves namespace lookups by ID or path and their usage through GraphQL.
+// The usage of the namespace "slow" is only answered once the request is cancelled.
+func fakeNamespaces(t *testing.T) *httptest.Server {
+	platform := map[string]interface{}{"id": 10, "full_path": "platform", "kind": "group"}
+	data := map[string]interface{}{"id": 20, "full_path": "data", "kind": "group", "shared_runners_minutes_limit": 1000}
+	backend := map[string]interface{}{"id": 30, "full_path": "platform/backend", "kind": "group", "parent_id": 10}
+	slow := map[string]interface{}{"id": 40, "full_path": "slow", "kind": "group"}
+	namespaces := map[string]map[string]interface{}{
+		"10": platform, "platform": platform, "old-platform": platform,
+		"20": data, "data": data,
+		"30":   backend,
+		"slow": slow,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v4/namespaces/"):
+			ns, ok := namespaces[strings.TrimPrefix(r.URL.Path, "/api/v4/namespaces/")]
+			if !ok {
+				http.Error(w, `{"message":"404 Namespace Not Found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(ns)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/application/settings":
+			json.NewEncoder(w).Encode(map[string]interface{}{"shared_runners_minutes": 400})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/graphql":
+			var query graphQLRequest
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if query.Variables["fullPath"] == "slow" {
+				<-r.Context().Done()
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"namespace": map[string]interface{}{
+					"storageSizeLimit":      1e9,
+					"rootStorageStatistics": map[string]interface{}{"storageSize": 5e8, "repositorySize": 4e8},
+				},
+				"ciMinutesUsage": map[string]interface{}{"nodes": []map[string]interface{}{
+					{"monthIso8601": time.Now().UTC().Format("2006-01-02"), "minutes": 120},
+				}},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNamespaceQuotasFetch(t *testing.T) {
+	server := fakeNamespaces(t)
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		wantPaths  []string
+		wantErr    bool
+	}{
+		{name: "ID, path and redirected path of one namespace", namespaces: []string{"platform", "10", "old-platform", "data"}, wantPaths: []string{"data", "platform"}},
+		{name: "nested namespace is skipped", namespaces: []string{"30", "data"}, wantPaths: []string{"data"}},
+		{name: "slow namespace is left out", namespaces: []string{"slow", "data", "platform"}, wantPaths: []string{"data", "platform"}},
+		{name: "no namespace read", namespaces: []string{"slow"}, wantErr: true},
+		{name: "unknown namespace", namespaces: []string{"missing", "data"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GitLab{Name: "test", URL: server.URL + "/api/v4"}
+			s := NewSource(cfg, nil, audit.New(""), time.Minute, 5*time.Second)
+			gitClient, err := CreateGitLabClient(cfg, "glpat-admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.setClient(gitClient, &Token{Token: "glpat-admin"})
+
+			n := NewNamespaceQuotas(s, config.NamespaceQuotas{
+				Namespaces:       tt.namespaces,
+				MaxNamespaces:    10,
+				Concurrency:      2,
+				NamespaceTimeout: 50 * time.Millisecond,
+			})
+			result, err := n.Fetch(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var paths []string
+			for _, usage := range result.Details.([]NamespaceUsage) {
+				paths = append(paths, usage.FullPath)
+				if usage.CIMinutesUsed != 120 || usage.StorageLimit != 1e9 {
+					t.Errorf("usage of %s = %+v", usage.FullPath, usage)
+				}
+				// data has its own minute quota, platform uses the instance default
+				if want := map[string]float64{"data": 1000, "platform": 400}[usage.FullPath]; usage.CIMinutesQuota != want {
+					t.Errorf("CIMinutesQuota of %s = %g, want %g", usage.FullPath, usage.CIMinutesQuota, want)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("Fetch() namespaces = %q, want %q", paths, tt.wantPaths)
+			}
+		})
+	}
+}
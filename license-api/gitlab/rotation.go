@@ -0,0 +1,227 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Retries of the token store writes and token verification during rotation, the backoff doubles after each attempt.
+// They are variables so tests can shorten them.
+var (
+	rotationAttempts = 5
+	rotationBackoff  = 2 * time.Second
+)
+
+// RunRotation checks the access token every rotation check interval until ctx is done and rotates it
+// once fewer than the configured lead days of validity remain. It runs independently of license scrapes.
+func (s *Source) RunRotation(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.RotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.checkRotation(ctx); err != nil {
+			log.Printf("Token rotation check failed for GitLab instance %s: %v", s.cfg.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkRotation exports the days left on the access token and rotates it when within the lead time.
+// A rotated token that is not committed yet is committed first.
+func (s *Source) checkRotation(ctx context.Context) error {
+	setupCtx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+	_, err := s.client(setupCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	uncommitted := s.uncommitted
+	s.mu.Unlock()
+	if uncommitted != nil {
+		log.Printf("GitLab token %d for instance %s is not committed to the token store yet, retrying", uncommitted.ID, s.cfg.Name)
+		if err := s.commit(ctx); err != nil {
+			rotationFailuresMetric.WithLabelValues(s.cfg.Name).Inc()
+			return source.WithReason(source.ReasonRotation, err)
+		}
+		lastRotationMetric.WithLabelValues(s.cfg.Name).SetToCurrentTime()
+	}
+
+	s.mu.Lock()
+	gitClient, token := s.gitClient, s.token
+	s.mu.Unlock()
+
+	daysUntilExpiry, err := token.TokenExpiryDays()
+	if err != nil {
+		rotationFailuresMetric.WithLabelValues(s.cfg.Name).Inc()
+		return source.WithReason(source.ReasonRotation, err)
+	}
+	patDaysUntilExpiryMetric.WithLabelValues(s.cfg.Name).Set(float64(daysUntilExpiry))
+	if daysUntilExpiry >= s.cfg.RotationLeadDays {
+		return nil
+	}
+
+	log.Printf("GitLab token for instance %s expires in %d days, rotating", s.cfg.Name, daysUntilExpiry)
+	newToken, err := s.rotate(ctx, gitClient, token)
+	if err != nil {
+		rotationFailuresMetric.WithLabelValues(s.cfg.Name).Inc()
+		return source.WithReason(source.ReasonRotation, err)
+	}
+	lastRotationMetric.WithLabelValues(s.cfg.Name).SetToCurrentTime()
+
+	if daysUntilExpiry, err := newToken.TokenExpiryDays(); err == nil {
+		patDaysUntilExpiryMetric.WithLabelValues(s.cfg.Name).Set(float64(daysUntilExpiry))
+	}
+	return nil
+}
+
+// rotate rotates the access token in GitLab and commits the new one. GitLab revokes the old token
+// immediately, so from then on the new token is kept in memory until commit succeeds.
+func (s *Source) rotate(ctx context.Context, gitClient *gitlab.Client, token *Token) (*Token, error) {
+	// Rotation is not retried, retrying with the old token would fail once GitLab has revoked it
+	rotateCtx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+	newToken, err := RotateToken(rotateCtx, gitClient, s.cfg, token)
+	cancel()
+	s.record("rotate", fmt.Sprintf("%s token %d", s.cfg.TokenType, token.ID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.uncommitted, s.unstaged = newToken, true
+	s.mu.Unlock()
+	if err := s.commit(ctx); err != nil {
+		return nil, err
+	}
+	return newToken, nil
+}
+
+// commit makes sure a failure at any step leaves a usable token behind: the uncommitted token is
+// written to the staging store, verified against GitLab and only then committed to the token store,
+// after which the staged copy is cleared. A failed step keeps the token in memory and is retried on
+// the next rotation check, the client switches to the token as soon as it is verified.
+func (s *Source) commit(ctx context.Context) error {
+	s.mu.Lock()
+	token, unstaged, store, staging := s.uncommitted, s.unstaged, s.store, s.staging
+	s.mu.Unlock()
+
+	// The old token is revoked now, stage the new one before anything else can fail
+	if unstaged {
+		err := s.retry(ctx, func(ctx context.Context) error { return WriteToken(ctx, staging, token) })
+		s.record("stage", fmt.Sprintf("token %d expires %s", token.ID, token.ExpiresAt), err)
+		if err == nil {
+			s.mu.Lock()
+			if s.uncommitted == token {
+				s.unstaged = false
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	gitClient, err := CreateGitLabClient(s.cfg, token.Token)
+	if err == nil {
+		err = s.retry(ctx, func(ctx context.Context) error { return VerifyToken(ctx, gitClient, token) })
+	}
+	s.record("verify", fmt.Sprintf("token %d", token.ID), err)
+	if err != nil {
+		return err
+	}
+
+	// From here the new token works, use it even if it cannot be committed yet
+	s.setClient(gitClient, token)
+	err = s.retry(ctx, func(ctx context.Context) error { return WriteToken(ctx, store, token) })
+	s.record("commit", fmt.Sprintf("token %d", token.ID), err)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.uncommitted == token {
+		s.uncommitted, s.unstaged = nil, false
+	}
+	s.mu.Unlock()
+	s.clearStaged(ctx, staging)
+	return nil
+}
+
+// recoverStaged returns a client for the staged token when it verifies, replacing a token rejected by
+// GitLab. verifyErr is returned when there is no usable staged token. The staged token is committed
+// to the token store by the next rotation check.
+func (s *Source) recoverStaged(ctx context.Context, staging secretstore.Store, verifyErr error) (*gitlab.Client, *Token, error) {
+	staged, err := ReadToken(ctx, staging)
+	if err != nil || staged.Token == "" {
+		return nil, nil, verifyErr
+	}
+	gitClient, err := CreateGitLabClient(s.cfg, staged.Token)
+	if err != nil {
+		return nil, nil, verifyErr
+	}
+	if err := VerifyToken(ctx, gitClient, staged); err != nil {
+		return nil, nil, verifyErr
+	}
+	s.record("recover", fmt.Sprintf("token %d from staging", staged.ID), nil)
+	return gitClient, staged, nil
+}
+
+// clearStaged overwrites the staged token once it is committed, so no copy is left behind.
+// A failure is only recorded, a stale staged token is never recovered over a working one.
+func (s *Source) clearStaged(ctx context.Context, staging secretstore.Store) {
+	err := s.retry(ctx, func(ctx context.Context) error { return WriteToken(ctx, staging, &Token{}) })
+	s.record("clear_staged", "", err)
+}
+
+// retry calls op until it succeeds or the attempts run out, backing off between attempts
+func (s *Source) retry(ctx context.Context, op func(context.Context) error) error {
+	backoff := rotationBackoff
+	for attempt := 1; ; attempt++ {
+		opCtx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+		err := op(opCtx)
+		cancel()
+		if err == nil || attempt == rotationAttempts {
+			return err
+		}
+
+		log.Printf("Attempt %d of %d failed for GitLab instance %s, retrying in %s: %v", attempt, rotationAttempts, s.cfg.Name, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// record writes a token rotation step to the audit trail
+func (s *Source) record(step, detail string, err error) {
+	outcome := audit.OK
+	if err != nil {
+		outcome = audit.Failed
+	}
+	s.auditor.Record(audit.Event{
+		Instance: s.cfg.Name,
+		Action:   "token_rotation",
+		Step:     step,
+		Outcome:  outcome,
+		Detail:   detail,
+	}, err)
+}
+
+// setClient switches to a client using the given token
+func (s *Source) setClient(gitClient *gitlab.Client, token *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gitClient, s.token = gitClient, token
+}
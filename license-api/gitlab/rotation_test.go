@@ -0,0 +1,254 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+	"github.com/gauravkr19/prometheus-exporters/source"
+)
+
+func init() {
+	rotationAttempts = 2
+	rotationBackoff = time.Millisecond
+}
+
+// fakeGitLab serves the personal access token API: self lookup and rotation by ID
+type fakeGitLab struct {
+	mu sync.Mutex
+	// tokens maps token values to their ID, revoked tokens are removed
+	tokens map[string]int
+	nextID int
+	// selfDown fails every self lookup, with a status the client does not retry by itself
+	selfDown bool
+}
+
+func newFakeGitLab(t *testing.T, token string, id int) (*fakeGitLab, *httptest.Server) {
+	f := &fakeGitLab{tokens: map[string]int{token: id}, nextID: id + 1}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, ok := f.tokens[r.Header.Get("Private-Token")]
+	if !ok {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v4/")
+	switch {
+	case r.Method == http.MethodGet && path == "personal_access_tokens/self":
+		if f.selfDown {
+			http.Error(w, `{"message":"400 Bad request"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "active": true, "revoked": false})
+	case r.Method == http.MethodPost && path == "personal_access_tokens/"+strconv.Itoa(id)+"/rotate":
+		for value, tokenID := range f.tokens {
+			if tokenID == id {
+				delete(f.tokens, value)
+			}
+		}
+		value := fmt.Sprintf("glpat-%d", f.nextID)
+		f.tokens[value] = f.nextID
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         f.nextID,
+			"active":     true,
+			"token":      value,
+			"expires_at": time.Now().AddDate(0, 0, 90).Format("2006-01-02"),
+		})
+		f.nextID++
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitLab) setSelfDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.selfDown = down
+}
+
+// flakyStore fails every write while down
+type flakyStore struct {
+	*secretstore.Memory
+	mu   sync.Mutex
+	down bool
+}
+
+func (f *flakyStore) Write(ctx context.Context, data map[string]interface{}) error {
+	f.mu.Lock()
+	down := f.down
+	f.mu.Unlock()
+	if down {
+		return errors.New("store unavailable")
+	}
+	return f.Memory.Write(ctx, data)
+}
+
+func (f *flakyStore) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func tokenData(id int, value string) map[string]interface{} {
+	return map[string]interface{}{"id": float64(id), "expires_at": time.Now().AddDate(0, 0, 30).Format("2006-01-02"), "active": true, "token": value}
+}
+
+// newRotationSource returns a Source for the fake GitLab set up with token 1 from store
+func newRotationSource(t *testing.T, url string, store, staging secretstore.Store) *Source {
+	t.Helper()
+	cfg := config.GitLab{Name: "test", URL: url + "/api/v4", TokenType: config.TokenPersonal, TokenExpiryDays: 90}
+	s := NewSource(cfg, nil, audit.New(""), time.Minute, 5*time.Second)
+	token, err := ReadToken(context.Background(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitClient, err := CreateGitLabClient(cfg, token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.gitClient, s.token, s.store, s.staging = gitClient, token, store, staging
+	return s
+}
+
+func storedToken(t *testing.T, store secretstore.Store) *Token {
+	t.Helper()
+	token, err := ReadToken(context.Background(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		stagingDown bool
+		storeDown   bool
+		selfDown    bool
+		// wantStaged is the token left in the staging store, empty when it was cleared
+		wantStaged   string
+		wantStored   string
+		wantClient   string
+		wantErr      bool
+		wantUnstaged bool
+	}{
+		{name: "committed", wantStored: "glpat-2", wantClient: "glpat-2"},
+		{name: "token store down", storeDown: true, wantStaged: "glpat-2", wantStored: "glpat-1", wantClient: "glpat-2", wantErr: true},
+		{name: "staging down", stagingDown: true, wantStored: "glpat-2", wantClient: "glpat-2"},
+		{name: "staging down and verification fails", stagingDown: true, selfDown: true, wantStored: "glpat-1", wantClient: "glpat-1", wantErr: true, wantUnstaged: true},
+		{name: "verification fails", selfDown: true, wantStaged: "glpat-2", wantStored: "glpat-1", wantClient: "glpat-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeGitLab(t, "glpat-1", 1)
+			store := &flakyStore{Memory: secretstore.NewMemory(tokenData(1, "glpat-1"))}
+			staging := &flakyStore{Memory: secretstore.NewMemory(nil)}
+			s := newRotationSource(t, server.URL, store, staging)
+
+			store.setDown(tt.storeDown)
+			staging.setDown(tt.stagingDown)
+			fake.setSelfDown(tt.selfDown)
+			ctx := context.Background()
+			_, err := s.rotate(ctx, s.gitClient, s.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rotate() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := storedToken(t, store).Token; got != tt.wantStored {
+				t.Errorf("token store holds %q, want %q", got, tt.wantStored)
+			}
+			if staged, err := ReadToken(ctx, staging); err == nil && staged.Token != tt.wantStaged {
+				t.Errorf("staging store holds %q, want %q", staged.Token, tt.wantStaged)
+			} else if err != nil && tt.wantStaged != "" {
+				t.Errorf("staging store holds no token, want %q", tt.wantStaged)
+			}
+			if s.token.Token != tt.wantClient {
+				t.Errorf("client uses %q, want %q", s.token.Token, tt.wantClient)
+			}
+			if s.unstaged != tt.wantUnstaged {
+				t.Errorf("unstaged = %v, want %v", s.unstaged, tt.wantUnstaged)
+			}
+			if !tt.wantErr {
+				if s.uncommitted != nil {
+					t.Errorf("token %d left uncommitted", s.uncommitted.ID)
+				}
+				return
+			}
+
+			// The new token is kept in memory and committed once the stores and GitLab recover
+			if s.uncommitted == nil || s.uncommitted.Token != "glpat-2" {
+				t.Fatalf("uncommitted = %+v, want token glpat-2", s.uncommitted)
+			}
+			store.setDown(false)
+			staging.setDown(false)
+			fake.setSelfDown(false)
+			if err := s.checkRotation(ctx); err != nil {
+				t.Fatalf("checkRotation() error = %v", err)
+			}
+			if got := storedToken(t, store).Token; got != "glpat-2" {
+				t.Errorf("token store holds %q after retry, want glpat-2", got)
+			}
+			if got := storedToken(t, staging).Token; got != "" {
+				t.Errorf("staging store holds %q after retry, want it cleared", got)
+			}
+			if s.uncommitted != nil || s.token.Token != "glpat-2" {
+				t.Errorf("after retry uncommitted = %+v and client uses %q, want nil and glpat-2", s.uncommitted, s.token.Token)
+			}
+		})
+	}
+}
+
+func TestRecoverStaged(t *testing.T) {
+	verifyErr := source.WithReason(source.ReasonAuth, errors.New("token revoked"))
+	tests := []struct {
+		name    string
+		staged  map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "verified staged token", staged: tokenData(2, "glpat-2"), want: "glpat-2"},
+		{name: "cleared staging store", staged: tokenData(0, ""), wantErr: true},
+		{name: "empty staging store", staged: nil, wantErr: true},
+		{name: "revoked staged token", staged: tokenData(3, "glpat-3"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, server := newFakeGitLab(t, "glpat-2", 2)
+			store := secretstore.NewMemory(tokenData(1, "glpat-1"))
+			staging := secretstore.NewMemory(tt.staged)
+			s := newRotationSource(t, server.URL, store, staging)
+
+			gitClient, token, err := s.recoverStaged(context.Background(), staging, verifyErr)
+			if tt.wantErr {
+				if err != verifyErr {
+					t.Errorf("recoverStaged() error = %v, want the verification error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("recoverStaged() error = %v", err)
+			}
+			if gitClient == nil || token.Token != tt.want {
+				t.Errorf("recoverStaged() = %v, %+v, want a client for %q", gitClient, token, tt.want)
+			}
+		})
+	}
+}
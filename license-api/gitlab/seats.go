@@ -0,0 +1,248 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xanzy/go-gitlab"
+)
+
+// activityNever is the last-activity bucket of users that were never active
+const activityNever = "never"
+
+// SeatCounts is the seat breakdown of one GitLab instance
+type SeatCounts struct {
+	// Users counts human users by state, bots are counted separately
+	Users    map[string]int
+	Bots     int
+	Billable int
+	// Activity counts billable users by last-activity bucket, e.g. "0-30d", "180d+" or "never"
+	Activity map[string]int
+	// Groups counts the billable members of each configured group
+	Groups map[string]int
+}
+
+// Seats breaks the seats of a GitLab instance down by user state, last activity and group
+// with the client of its license Source. It needs an admin token.
+type Seats struct {
+	source  *Source
+	cfg     config.SeatBreakdown
+	cache   *source.Cache
+	metrics seatMetrics
+}
+
+// NewSeats returns the seat breakdown collector of the instance of s
+func NewSeats(s *Source, cfg config.SeatBreakdown) *Seats {
+	seats := &Seats{
+		source:  s,
+		cfg:     cfg,
+		metrics: newSeatMetrics(s.Instance()),
+	}
+	seats.cache = source.NewCache(seats, cfg.CacheTTL, cfg.FetchTimeout)
+	return seats
+}
+
+// Name returns the name of the collector.
+func (seats *Seats) Name() string {
+	return "gitlab_seats"
+}
+
+// Instance returns the configured instance name of the collector.
+func (seats *Seats) Instance() string {
+	return seats.source.Instance()
+}
+
+// Fetch pages through the users and billable group members, Details holds the SeatCounts.
+func (seats *Seats) Fetch(ctx context.Context) (*source.Result, error) {
+	gitClient, err := seats.source.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := listUsers(ctx, gitClient)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &SeatCounts{
+		Users:    make(map[string]int),
+		Activity: make(map[string]int),
+		Groups:   make(map[string]int),
+	}
+	now := time.Now()
+	for _, user := range billableUsers(users) {
+		counts.Billable++
+		counts.Activity[activityBucket(user, now, seats.cfg.ActivityBucketDays)]++
+	}
+	for _, user := range users {
+		if user.Bot {
+			counts.Bots++
+			continue
+		}
+		counts.Users[user.State]++
+	}
+
+	for _, group := range seats.cfg.Groups {
+		members, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.BillableGroupMember, *gitlab.Response, error) {
+			return gitClient.Groups.ListBillableGroupMembers(group, &gitlab.ListBillableGroupMembersOptions{
+				ListOptions: gitlab.ListOptions{Page: page, PerPage: perPage},
+			}, options...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list billable members of group %s: %w", group, err)
+		}
+		counts.Groups[group] = len(members)
+	}
+
+	return &source.Result{Details: counts}, nil
+}
+
+// listUsers pages through all users of the instance except internal ones such as the ghost user
+func listUsers(ctx context.Context, gitClient *gitlab.Client) ([]*gitlab.User, error) {
+	users, err := listAll(ctx, func(page int, options ...gitlab.RequestOptionFunc) ([]*gitlab.User, *gitlab.Response, error) {
+		return gitClient.Users.ListUsers(&gitlab.ListUsersOptions{
+			ListOptions:     gitlab.ListOptions{Page: page, PerPage: perPage},
+			ExcludeInternal: gitlab.Ptr(true),
+		}, options...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// billableUsers returns the users holding a license seat. GitLab only reports using_license_seat
+// to admins on licensed instances, when no user reports it active human users are counted instead.
+func billableUsers(users []*gitlab.User) []*gitlab.User {
+	var seated, active []*gitlab.User
+	for _, user := range users {
+		if user.Bot || user.State != "active" {
+			continue
+		}
+		active = append(active, user)
+		if user.UsingLicenseSeat {
+			seated = append(seated, user)
+		}
+	}
+	if len(seated) == 0 {
+		return active
+	}
+	return seated
+}
+
+// lastActivity returns when the user was last active or signed in, whichever is later
+func lastActivity(user *gitlab.User) *time.Time {
+	var last *time.Time
+	if user.LastActivityOn != nil {
+		t := time.Time(*user.LastActivityOn)
+		last = &t
+	}
+	if user.LastSignInAt != nil && (last == nil || user.LastSignInAt.After(*last)) {
+		last = user.LastSignInAt
+	}
+	return last
+}
+
+// activityBucket returns the last-activity bucket of the user, bounds are in days
+func activityBucket(user *gitlab.User, now time.Time, bounds []int) string {
+	last := lastActivity(user)
+	if last == nil {
+		return activityNever
+	}
+
+	days := int(now.Sub(*last).Hours() / 24)
+	lower := 0
+	for _, upper := range bounds {
+		if days < upper {
+			return fmt.Sprintf("%d-%dd", lower, upper)
+		}
+		lower = upper
+	}
+	return strconv.Itoa(lower) + "d+"
+}
+
+// seatMetrics holds the Prometheus descriptors of the seat breakdown for one instance
+type seatMetrics struct {
+	users          *prometheus.Desc
+	bots           *prometheus.Desc
+	billable       *prometheus.Desc
+	activity       *prometheus.Desc
+	groupsBillable *prometheus.Desc
+}
+
+// newSeatMetrics creates the seat breakdown descriptors with the instance as a constant label
+func newSeatMetrics(instance string) seatMetrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return seatMetrics{
+		users: prometheus.NewDesc(
+			"gitlab_users",
+			"Human GitLab users by state, bots excluded",
+			[]string{"state"},
+			constLabels,
+		),
+		bots: prometheus.NewDesc(
+			"gitlab_bot_users",
+			"GitLab bot users, such as group and project access token bots",
+			nil,
+			constLabels,
+		),
+		billable: prometheus.NewDesc(
+			"gitlab_billable_users",
+			"GitLab users holding a license seat",
+			nil,
+			constLabels,
+		),
+		activity: prometheus.NewDesc(
+			"gitlab_billable_users_by_last_activity",
+			"GitLab users holding a license seat by days since their last activity",
+			[]string{"last_activity"},
+			constLabels,
+		),
+		groupsBillable: prometheus.NewDesc(
+			"gitlab_group_billable_members",
+			"Billable members of a GitLab group, including its subgroups and projects",
+			[]string{"group"},
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the seat breakdown metric descriptors.
+func (seats *Seats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- seats.metrics.users
+	ch <- seats.metrics.bots
+	ch <- seats.metrics.billable
+	ch <- seats.metrics.activity
+	ch <- seats.metrics.groupsBillable
+	seats.cache.Describe(ch)
+}
+
+// Collect fetches the seat breakdown through the cache and sends it as Prometheus metrics.
+func (seats *Seats) Collect(ch chan<- prometheus.Metric) {
+	result, err := seats.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch GitLab seat breakdown for instance %s: %v", seats.Instance(), err)
+	}
+	if result == nil {
+		return
+	}
+
+	counts := result.Details.(*SeatCounts)
+	for state, count := range counts.Users {
+		ch <- prometheus.MustNewConstMetric(seats.metrics.users, prometheus.GaugeValue, float64(count), state)
+	}
+	ch <- prometheus.MustNewConstMetric(seats.metrics.bots, prometheus.GaugeValue, float64(counts.Bots))
+	ch <- prometheus.MustNewConstMetric(seats.metrics.billable, prometheus.GaugeValue, float64(counts.Billable))
+	for bucket, count := range counts.Activity {
+		ch <- prometheus.MustNewConstMetric(seats.metrics.activity, prometheus.GaugeValue, float64(count), bucket)
+	}
+	for group, count := range counts.Groups {
+		ch <- prometheus.MustNewConstMetric(seats.metrics.groupsBillable, prometheus.GaugeValue, float64(count), group)
+	}
+}
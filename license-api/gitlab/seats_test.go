@@ -0,0 +1,44 @@
+package gitlab
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestActivityBucket(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		t := now.AddDate(0, 0, -days)
+		return &t
+	}
+	activeOn := func(days int) *gitlab.ISOTime {
+		t := gitlab.ISOTime(*daysAgo(days))
+		return &t
+	}
+	bounds := []int{30, 60, 90, 180}
+
+	tests := []struct {
+		name string
+		user *gitlab.User
+		want string
+	}{
+		{name: "never active", user: &gitlab.User{}, want: activityNever},
+		{name: "active today", user: &gitlab.User{LastActivityOn: activeOn(0)}, want: "0-30d"},
+		{name: "just below a bound", user: &gitlab.User{LastActivityOn: activeOn(29)}, want: "0-30d"},
+		{name: "on a bound", user: &gitlab.User{LastActivityOn: activeOn(30)}, want: "30-60d"},
+		{name: "last bucket", user: &gitlab.User{LastActivityOn: activeOn(179)}, want: "90-180d"},
+		{name: "beyond the last bound", user: &gitlab.User{LastActivityOn: activeOn(400)}, want: "180d+"},
+		{name: "only signed in", user: &gitlab.User{LastSignInAt: daysAgo(65)}, want: "60-90d"},
+		{name: "sign-in after activity", user: &gitlab.User{LastActivityOn: activeOn(100), LastSignInAt: daysAgo(10)}, want: "0-30d"},
+		{name: "activity after sign-in", user: &gitlab.User{LastActivityOn: activeOn(10), LastSignInAt: daysAgo(100)}, want: "0-30d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activityBucket(tt.user, now, bounds); got != tt.want {
+				t.Errorf("activityBucket() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/gauravkr19/prometheus-exporters/vault"
+)
+
+// NewTokenStore returns the secret store holding the GitLab rotation token.
+// Vault stores share the exporter's Vault session, which is nil when no token is stored in Vault.
+func NewTokenStore(cfg config.TokenStore, session *vault.Session) (secretstore.Store, error) {
+	switch cfg.Type {
+	case config.StoreVaultKV2, config.StoreVaultKV1:
+		if session == nil {
+			return nil, source.WithReason(source.ReasonVault, fmt.Errorf("no Vault session for token store %s", cfg.Path))
+		}
+		if cfg.Type == config.StoreVaultKV1 {
+			return secretstore.NewVaultKV1(session.Client(), cfg.Path, session.Login), nil
+		}
+		return secretstore.NewVaultKV2(session.Client(), cfg.Path, session.Login), nil
+	case config.StoreKubernetes:
+		return secretstore.NewKubernetes(cfg.Namespace, cfg.Name)
+	case config.StoreFile:
+		return secretstore.NewFile(cfg.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown token store type %q", cfg.Type)
+	}
+}
+
+// ReadToken reads the GitLab token from the token store
+func ReadToken(ctx context.Context, store secretstore.Store) (*Token, error) {
+	data, err := store.Read(ctx)
+	if err != nil {
+		return nil, source.WithReason(source.ReasonSecretStore, err)
+	}
+
+	token, err := tokenFromData(data)
+	if err != nil {
+		return nil, source.WithReason(source.ReasonSecretStore, err)
+	}
+	return token, nil
+}
+
+// tokenFromData converts the fields read from a token store into a Token. The id is a float64 or
+// json.Number from JSON backends, a string from Kubernetes and the int written to a Memory store.
+func tokenFromData(data map[string]interface{}) (*Token, error) {
+	var err error
+
+	var id int
+	switch v := data["id"].(type) {
+	case string:
+		id, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("error converting id string to int: %w", err)
+		}
+	case int:
+		id = v
+	case float64:
+		id = int(v)
+	case json.Number:
+		id, err = strconv.Atoi(v.String())
+		if err != nil {
+			return nil, fmt.Errorf("error converting id json.Number to int: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("error converting id to int: unexpected type %T", v)
+	}
+
+	expiresAt, ok := data["expires_at"].(string)
+	if !ok {
+		return nil, fmt.Errorf("error converting expires_at to string")
+	}
+
+	var active bool
+	switch v := data["active"].(type) {
+	case string:
+		active, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("error converting active string to bool: %w", err)
+		}
+	case bool:
+		active = v
+	default:
+		return nil, fmt.Errorf("error converting active to bool: unexpected type %T", v)
+	}
+
+	token, ok := data["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("error converting token to string")
+	}
+
+	return &Token{
+		ID:        id,
+		ExpiresAt: expiresAt,
+		Active:    active,
+		Token:     token,
+	}, nil
+}
+
+// WriteToken writes the token to the token store.
+func WriteToken(ctx context.Context, store secretstore.Store, token *Token) error {
+	data := map[string]interface{}{
+		"id":         token.ID,
+		"expires_at": token.ExpiresAt,
+		"active":     token.Active,
+		"token":      token.Token,
+	}
+
+	if err := store.Write(ctx, data); err != nil {
+		return fmt.Errorf("error writing token to the token store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+)
+
+func TestTokenFromData(t *testing.T) {
+	want := &Token{ID: 42, ExpiresAt: "2026-12-31", Active: true, Token: "glpat-42"}
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    *Token
+		wantErr bool
+	}{
+		{
+			name: "Vault KV JSON types",
+			data: map[string]interface{}{"id": float64(42), "expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+			want: want,
+		},
+		{
+			name: "Kubernetes string values",
+			data: map[string]interface{}{"id": "42", "expires_at": "2026-12-31", "active": "true", "token": "glpat-42"},
+			want: want,
+		},
+		{
+			name: "json.Number id",
+			data: map[string]interface{}{"id": json.Number("42"), "expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+			want: want,
+		},
+		{
+			name: "int id as written",
+			data: map[string]interface{}{"id": 42, "expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+			want: want,
+		},
+		{
+			name:    "missing id",
+			data:    map[string]interface{}{"expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric id",
+			data:    map[string]interface{}{"id": "forty-two", "expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+			wantErr: true,
+		},
+		{
+			name:    "non-boolean active",
+			data:    map[string]interface{}{"id": "42", "expires_at": "2026-12-31", "active": "yes", "token": "glpat-42"},
+			wantErr: true,
+		},
+		{
+			name:    "missing token",
+			data:    map[string]interface{}{"id": "42", "expires_at": "2026-12-31", "active": "true"},
+			wantErr: true,
+		},
+		{
+			name:    "numeric expiry",
+			data:    map[string]interface{}{"id": "42", "expires_at": 20261231, "active": "true", "token": "glpat-42"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenFromData(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tokenFromData() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenFromData() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteTokenRoundTrip reads back the token written to stores returning the id as written (Memory)
+// and as a JSON number (File)
+func TestWriteTokenRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		store secretstore.Store
+	}{
+		{name: "memory", store: secretstore.NewMemory(nil)},
+		{name: "file", store: secretstore.NewFile(filepath.Join(t.TempDir(), "token.json"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			token := &Token{ID: 7, ExpiresAt: "2026-12-31", Active: true, Token: "glpat-7"}
+			if err := WriteToken(ctx, tt.store, token); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadToken(ctx, tt.store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, token) {
+				t.Errorf("ReadToken() = %+v, want %+v", got, token)
+			}
+		})
+	}
+}
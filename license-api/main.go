@@ -1,64 +1,151 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"time"
-    "net/http"
-
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
 	"github.com/gauravkr19/prometheus-exporters/gitlab"
 	"github.com/gauravkr19/prometheus-exporters/nexus"
+	"github.com/gauravkr19/prometheus-exporters/renewal"
 	"github.com/gauravkr19/prometheus-exporters/sonar"
-    "github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/gauravkr19/prometheus-exporters/vault"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// License keys are applied to the sources implementing renewal.Target
+var (
+	_ renewal.Target = (*gitlab.Source)(nil)
+	_ renewal.Target = (*nexus.Source)(nil)
+	_ renewal.Target = (*sonar.Source)(nil)
 )
 
+// registry holds the enabled license sources, each collected on scrape
+type registry struct {
+	sources []source.Source
+}
+
+// register adds a license source to the registry and to the Prometheus default registry
+func (r *registry) register(s source.Source) {
+	prometheus.MustRegister(s)
+	log.Printf("Registered %s license source for instance %s", s.Name(), s.Instance())
+	r.sources = append(r.sources, s)
+}
+
+// warm collects every source once so the first scrape after a restart is served from cache
+func (r *registry) warm() {
+	if _, err := prometheus.DefaultGatherer.Gather(); err != nil {
+		log.Printf("Failed to warm license caches: %v", err)
+	}
+}
+
 // Start Prometheus endpoint
-func StartPrometheusEndpoint() {
+func StartPrometheusEndpoint(listenAddress string) {
 	http.Handle("/metrics", promhttp.Handler())
-    log.Println("Starting License exporter server at :8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	log.Printf("Starting License exporter server at %s", listenAddress)
+	log.Fatal(http.ListenAndServe(listenAddress, nil))
 }
 
 func main() {
-	// GitLab setup
-	gitClient, gitlabToken, vaultClient, vaultKVPath := gitlab.SetupGitLab()
-	
-    // Nexus.Sonar setup
-	nexusClient, nexusConfig := nexus.SetupNexus()
-	sonarClient, sonarConfig := sonar.SetupSonar()
+	// Configuration file path, environment variables override individual fields
+	cfg, err := config.Load(os.Getenv("LICENSE_API_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-    go StartPrometheusEndpoint()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Initial license check
-	gitlab.UpdateGitlabLicense(gitClient)
-    go nexus.UpdateNexusLicense(nexusClient, nexusConfig)
-    go sonar.UpdateSonarLicense(sonarClient, sonarConfig)
-
-	ticker := time.NewTicker(6 * time.Hour)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			token := gitlab.Token{
-				ID:        gitlabToken.ID,
-				ExpiresAt: gitlabToken.ExpiresAt,
-				Active:    gitlabToken.Active,
-				Token:     gitlabToken.Token,
-			}
+	// One Vault session is shared by all token stores, it is renewed in the background and revoked on shutdown
+	var session *vault.Session
+	if cfg.UsesVault() {
+		session, err = vault.NewSession(cfg.Vault)
+		if err != nil {
+			log.Fatalf("Failed to set up Vault session: %v", err)
+		}
+	}
+	sessionDone := make(chan struct{})
+	go func() {
+		defer close(sessionDone)
+		if session != nil {
+			session.Run(ctx)
+		}
+	}()
 
-			if token.TokenExpiryDays() <= 0 {
-				gitlab.RotateTokenAndSetExpiry(gitClient, vaultClient, &token)
-				gitlabToken = gitlab.ReadVaultKV2(vaultClient, vaultKVPath)
+	// Changes made to the products and the token stores are recorded in the audit log
+	auditor := audit.New(cfg.AuditLog)
 
-				gitClient, _ = gitlab.CreateGitLabClient(gitlabToken.Token)
+	// Every configured instance is collected as its own source
+	var sources registry
+	for _, gl := range cfg.GitLab {
+		s := gitlab.NewSource(gl, session, auditor, cfg.CacheTTL, cfg.FetchTimeout)
+		sources.register(s)
+		// Token rotation has its own schedule, independent of license scrapes
+		go s.RunRotation(ctx)
 
+		// Optional collectors share the instance's client and token
+		if gl.TokenInventory.Enabled {
+			sources.register(gitlab.NewTokenInventory(s, gl.TokenInventory))
+		}
+		if gl.SeatBreakdown.Enabled {
+			sources.register(gitlab.NewSeats(s, gl.SeatBreakdown))
+		}
+		if gl.InactiveUsers.Enabled {
+			inactive := gitlab.NewInactiveUsers(s, gl.InactiveUsers)
+			sources.register(inactive)
+			if gl.InactiveUsers.Reclaim.Enabled {
+				go inactive.RunReclaim(ctx)
 			}
+		}
+		if gl.LicenseHistory.Enabled {
+			sources.register(gitlab.NewLicenseHistory(s, gl.LicenseHistory))
+		}
+		if gl.AddOns.Enabled {
+			sources.register(gitlab.NewAddOnPurchases(s, gl.AddOns))
+		}
+		if gl.NamespaceQuotas.Enabled {
+			sources.register(gitlab.NewNamespaceQuotas(s, gl.NamespaceQuotas))
+		}
+	}
+	for _, nx := range cfg.Nexus {
+		s, err := nexus.NewSource(nx, cfg.CacheTTL, cfg.FetchTimeout)
+		if err != nil {
+			log.Fatalf("Failed to set up Nexus instance %s: %v", nx.Name, err)
+		}
+		sources.register(s)
+	}
+	for _, sq := range cfg.Sonar {
+		s, err := sonar.NewSource(sq, cfg.CacheTTL, cfg.FetchTimeout)
+		if err != nil {
+			log.Fatalf("Failed to set up Sonar instance %s: %v", sq.Name, err)
+		}
+		sources.register(s)
+	}
 
-			gitlab.UpdateGitlabLicense(gitClient)
-            go nexus.UpdateNexusLicense(nexusClient, nexusConfig)
-            go sonar.UpdateSonarLicense(sonarClient, sonarConfig)
+	// New license keys are applied to the license sources on their own schedule
+	if cfg.LicenseRenewal.Enabled {
+		var targets []renewal.Target
+		for _, s := range sources.sources {
+			if target, ok := s.(renewal.Target); ok {
+				targets = append(targets, target)
+			}
 		}
+		go renewal.NewManager(cfg.LicenseRenewal, targets, session, auditor, cfg.FetchTimeout).Run(ctx)
 	}
-}
 
+	// Initial license check
+	go sources.warm()
+
+	go StartPrometheusEndpoint(cfg.ListenAddress)
+
+	<-ctx.Done()
+	log.Printf("Shutting down License exporter")
+	<-sessionDone
+}
@@ -1,6 +1,8 @@
 package nexus
 
 import (
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,36 +21,152 @@ type License struct {
 	DaysUntilExpiry int    `json:"daysUntilExpiry"`
 }
 
-// Prometheus metrics
-var (
-	licenseMetric = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "nexus_license_info",
-			Help: "Nexus License Information",
-		},
-		[]string{
-			"contact_email",
-			"contact_company",
-			"contact_name",
-			"effective_date",
-			"expiration_date",
-			"license_type",
-			"licensed_users",
-			"features",
-		},
-	)
-	daysUntilExpiryMetric = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "nexus_license_days_until_expiry",
-			Help: "Days until Nexus License expires",
-		},
+// metrics holds the Prometheus descriptors of the Nexus license metrics for one instance
+type metrics struct {
+	license         *prometheus.Desc
+	licensedUsers   *prometheus.Desc
+	daysUntilExpiry *prometheus.Desc
+	expiresAt       *prometheus.Desc
+	effectiveAt     *prometheus.Desc
+	edition         *prometheus.Desc
+	usage           *prometheus.Desc
+	usageLimit      *prometheus.Desc
+	utilization     *prometheus.Desc
+}
+
+// newMetrics creates the metric descriptors with the instance as a constant label
+func newMetrics(instance string) metrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return metrics{
+		license: prometheus.NewDesc(
+			"nexus_license_info",
+			"Nexus License Information, its dates are exported as timestamp gauges so the series does not change on renewal",
+			[]string{
+				"contact_email",
+				"contact_company",
+				"contact_name",
+				"license_type",
+				"features",
+			},
+			constLabels,
+		),
+		licensedUsers: prometheus.NewDesc(
+			"nexus_license_licensed_users",
+			"Users allowed by the Nexus License",
+			nil,
+			constLabels,
+		),
+		daysUntilExpiry: prometheus.NewDesc(
+			"nexus_license_days_until_expiry",
+			"Days until Nexus License expires",
+			nil,
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"nexus_license_expiry_timestamp_seconds",
+			"Unix timestamp when the Nexus License expires",
+			nil,
+			constLabels,
+		),
+		effectiveAt: prometheus.NewDesc(
+			"nexus_license_effective_timestamp_seconds",
+			"Unix timestamp when the Nexus License became effective",
+			nil,
+			constLabels,
+		),
+		edition: prometheus.NewDesc(
+			"nexus_edition_info",
+			"Edition and version of Nexus",
+			[]string{"edition", "version"},
+			constLabels,
+		),
+		usage: prometheus.NewDesc(
+			"nexus_usage",
+			"Usage metric of Nexus Community Edition, e.g. component_total_count or peak_requests_per_day",
+			[]string{"metric"},
+			constLabels,
+		),
+		usageLimit: prometheus.NewDesc(
+			"nexus_usage_limit",
+			"Usage limit of Nexus Community Edition by threshold, requests are throttled above the hard limit",
+			[]string{"metric", "threshold"},
+			constLabels,
+		),
+		utilization: prometheus.NewDesc(
+			"nexus_usage_utilization_ratio",
+			"Usage metric of Nexus Community Edition as a ratio of its hard limit",
+			[]string{"metric"},
+			constLabels,
+		),
+	}
+}
+
+// Describe sends the Nexus license metric descriptors.
+func (s *Source) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.metrics.license
+	ch <- s.metrics.licensedUsers
+	ch <- s.metrics.daysUntilExpiry
+	ch <- s.metrics.expiresAt
+	ch <- s.metrics.effectiveAt
+	ch <- s.metrics.edition
+	ch <- s.metrics.usage
+	ch <- s.metrics.usageLimit
+	ch <- s.metrics.utilization
+	s.cache.Describe(ch)
+}
+
+// Collect fetches the license through the cache and sends it as Prometheus metrics.
+func (s *Source) Collect(ch chan<- prometheus.Metric) {
+	result, err := s.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch Nexus license for instance %s: %v", s.instance, err)
+	}
+	if result == nil {
+		return
+	}
+
+	status := result.Details.(*Status)
+	ch <- prometheus.MustNewConstMetric(s.metrics.edition, prometheus.GaugeValue, 1, status.Edition, status.Version)
+
+	// The Community Edition is limited by usage rather than a license
+	for _, usage := range status.Usage {
+		ch <- prometheus.MustNewConstMetric(s.metrics.usage, prometheus.GaugeValue, usage.Value, usage.Key)
+		for _, threshold := range usage.Thresholds {
+			ch <- prometheus.MustNewConstMetric(s.metrics.usageLimit, prometheus.GaugeValue, threshold.Value, usage.Key, threshold.Limit())
+		}
+		if ratio, ok := usage.Utilization(); ok {
+			ch <- prometheus.MustNewConstMetric(s.metrics.utilization, prometheus.GaugeValue, ratio, usage.Key)
+		}
+	}
+	if status.License == nil {
+		return
+	}
+
+	// Days until expiry are computed against the current time, not the fetch time
+	license := NewLicense(*status.License)
+
+	// Set the license information metric
+	ch <- prometheus.MustNewConstMetric(s.metrics.license, prometheus.GaugeValue, 1,
+		license.ContactEmail,
+		license.ContactCompany,
+		license.ContactName,
+		license.LicenseType,
+		license.Features,
 	)
-)
 
-func init() {
-	// Register metrics with Prometheus
-	prometheus.MustRegister(licenseMetric)
-	prometheus.MustRegister(daysUntilExpiryMetric)
+	// Nexus reports licensedUsers as a string, it is not a number on unlimited licenses
+	if licensedUsers, err := strconv.ParseFloat(license.LicensedUsers, 64); err == nil {
+		ch <- prometheus.MustNewConstMetric(s.metrics.licensedUsers, prometheus.GaugeValue, licensedUsers)
+	}
+
+	// Set the days until expiry metric
+	ch <- prometheus.MustNewConstMetric(s.metrics.daysUntilExpiry, prometheus.GaugeValue, float64(license.DaysUntilExpiry))
+
+	// Timestamps let PromQL compute the exact time left, including negative values once expired
+	ch <- prometheus.MustNewConstMetric(s.metrics.expiresAt, prometheus.GaugeValue, float64(result.ExpiresAt.Unix()))
+	if effectiveDate, err := time.Parse(time.RFC3339, license.EffectiveDate); err == nil {
+		ch <- prometheus.MustNewConstMetric(s.metrics.effectiveAt, prometheus.GaugeValue, float64(effectiveDate.Unix()))
+	}
 }
 
 // NewLicense creates a new License instance.
@@ -68,21 +186,3 @@ func NewLicense(license License) License {
 		DaysUntilExpiry: daysUntilExpiry,
 	}
 }
-
-// RegisterMetrics registers license information as Prometheus metrics.
-func RegisterMetrics(license License) {
-	// Set the license information metric
-	licenseMetric.With(prometheus.Labels{
-		"contact_email":   license.ContactEmail,
-		"contact_company": license.ContactCompany,
-		"contact_name":    license.ContactName,
-		"effective_date":  license.EffectiveDate,
-		"expiration_date": license.ExpirationDate,
-		"license_type":    license.LicenseType,
-		"licensed_users":  license.LicensedUsers,
-		"features":        license.Features,
-	}).Set(1)
-
-	// Set the days until expiry metric
-	daysUntilExpiryMetric.Set(float64(license.DaysUntilExpiry))
-}
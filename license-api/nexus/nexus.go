@@ -1,61 +1,135 @@
 package nexus
 
 import (
-	"crypto/tls"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
 )
 
-// Config holds the configuration for Nexus client
+// Config holds the configuration for nexus client
 type Config struct {
 	URL      string
 	Username string
 	Password string
-	Insecure bool
+	TLS      config.TLS
 }
 
 // SetupNexus setsup nexus client
-func SetupNexus() (*http.Client, Config) {
+func SetupNexus(cfg config.Server) (*http.Client, Config, error) {
 	nexusConfig := Config{
-		URL:      os.Getenv("NEXUS_URL"),
-		Username: os.Getenv("NEXUS_USERNAME"),
-		Password: os.Getenv("NEXUS_PASSWORD"),
-		Insecure: true,
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		TLS:      cfg.TLS,
+	}
+	nexusClient, err := NewClient(nexusConfig)
+	if err != nil {
+		return nil, Config{}, err
 	}
-	nexusClient := NewClient(nexusConfig)
-	return nexusClient, nexusConfig
+	return nexusClient, nexusConfig, nil
 }
 
 // NewClient creates a new Nexus client
-func NewClient(config Config) *http.Client {
+func NewClient(config Config) (*http.Client, error) {
+	tlsConfig, err := config.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
+		TLSClientConfig: tlsConfig,
 	}
-	return &http.Client{Transport: transport}
+	return &http.Client{Transport: transport}, nil
 }
 
-// UpdateNexusLicense fetches and updates the Nexus license metrics
-func UpdateNexusLicense(client *http.Client, config Config) {
-	license, err := GetLicense(client, config)
+// Source polls the license API of one Nexus instance.
+type Source struct {
+	instance string
+	client   *http.Client
+	config   Config
+	cache    *source.Cache
+	metrics  metrics
+}
+
+// NewSource sets up the Nexus client and returns a Nexus license source, caching fetched licenses for cacheTTL.
+func NewSource(cfg config.Server, cacheTTL, fetchTimeout time.Duration) (*Source, error) {
+	client, clientConfig, err := SetupNexus(cfg)
 	if err != nil {
-		log.Printf("Failed to fetch Nexus license: %v", err)
-		return
+		return nil, err
 	}
+	s := &Source{instance: cfg.Name, client: client, config: clientConfig, metrics: newMetrics(cfg.Name)}
+	s.cache = source.NewCache(s, cacheTTL, fetchTimeout)
+	return s, nil
+}
+
+// Name returns the vendor name of the source.
+func (s *Source) Name() string {
+	return "nexus"
+}
+
+// Instance returns the configured instance name of the source.
+func (s *Source) Instance() string {
+	return s.instance
+}
+
+// Fetch detects the Nexus edition and fetches the license information of the Pro edition, or the usage
+// metrics on the Community Edition, which has no license API. Details holds the Status.
+func (s *Source) Fetch(ctx context.Context) (*source.Result, error) {
+	edition, version, err := GetEdition(ctx, s.client, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Nexus status: %w", err)
+	}
+	status := &Status{Edition: edition, Version: version}
+
+	switch edition {
+	case EditionCommunity:
+		usage, err := GetUsage(ctx, s.client, s.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch Nexus usage: %w", err)
+		}
+		status.Usage = usage
+		return &source.Result{Plan: edition, Details: status}, nil
+	case EditionOSS:
+		// Nexus OSS has neither a license nor usage limits
+		return &source.Result{Plan: edition, Details: status}, nil
+	case EditionPro:
+	default:
+		log.Printf("Nexus instance %s reports the unknown edition %q, its license and usage are not read", s.instance, edition)
+		return &source.Result{Plan: edition, Details: status}, nil
+	}
+
+	license, err := GetLicense(ctx, s.client, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Nexus license: %w", err)
+	}
+	status.License = &license
+
+	expiresAt, _ := time.Parse(time.RFC3339, license.ExpirationDate)
 
-	// Create a License instance and calculate DaysUntilExpiration
-	licenseInfo := NewLicense(license)
+	// Nexus reports licensedUsers as a string, it is not a number on unlimited licenses
+	licensedUsers, _ := strconv.Atoi(license.LicensedUsers)
 
-	RegisterMetrics(licenseInfo)
+	return &source.Result{
+		Plan:      license.LicenseType,
+		ExpiresAt: expiresAt,
+		Expired:   time.Now().After(expiresAt),
+		Quantity:  licensedUsers,
+		Details:   status,
+	}, nil
 }
 
 // GetLicense fetches the license information from Nexus
-func GetLicense(client *http.Client, config Config) (License, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/service/rest/v1/system/license", config.URL), nil)
+func GetLicense(ctx context.Context, client *http.Client, config Config) (License, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/service/rest/v1/system/license", config.URL), nil)
 	if err != nil {
 		return License{}, err
 	}
@@ -67,8 +141,12 @@ func GetLicense(client *http.Client, config Config) (License, error) {
 	}
 	defer resp.Body.Close()
 
+	// Nexus answers 402 Payment Required when no license is installed
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return License{}, source.WithReason(source.ReasonNoLicense, errors.New("no license is installed"))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return License{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return License{}, source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -78,15 +156,52 @@ func GetLicense(client *http.Client, config Config) (License, error) {
 
 	var license License
 	if err := json.Unmarshal(body, &license); err != nil {
-		return License{}, err
+		return License{}, source.WithReason(source.ReasonDecode, err)
 	}
 
 	// Calculate days until expiry
 	expiryDate, err := time.Parse(time.RFC3339, license.ExpirationDate)
 	if err != nil {
-		return License{}, err
+		return License{}, source.WithReason(source.ReasonDecode, err)
 	}
 	license.DaysUntilExpiry = int(time.Until(expiryDate).Hours() / 24)
 
 	return license, nil
 }
+
+// ApplyLicense installs a license file on the Nexus instance. Nexus license files cannot be read
+// before they are installed, so the caller checks the expiry it was declared with against current
+// and the license Nexus reports afterwards is checked against declared.
+func (s *Source) ApplyLicense(ctx context.Context, key []byte, declared, _ *source.Result) error {
+	if err := UploadLicense(ctx, s.client, s.config, key); err != nil {
+		return fmt.Errorf("failed to upload Nexus license: %w", err)
+	}
+	s.cache.Invalidate()
+
+	applied, err := s.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the applied Nexus license: %w", err)
+	}
+	return source.CheckApplied(declared, applied)
+}
+
+// UploadLicense installs a license file through the Nexus license API
+func UploadLicense(ctx context.Context, client *http.Client, config Config, key []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/service/rest/v1/system/license", config.URL), bytes.NewReader(key))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+	return nil
+}
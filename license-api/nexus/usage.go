@@ -0,0 +1,178 @@
+package nexus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gauravkr19/prometheus-exporters/source"
+)
+
+// Nexus editions as reported by the system information API and the Server header, lower-cased
+const (
+	EditionPro       = "pro"
+	EditionCommunity = "community"
+	EditionOSS       = "oss"
+)
+
+// serverHeader matches the Server header of Nexus, e.g. "Nexus/3.77.1-01 (COMMUNITY)"
+var serverHeader = regexp.MustCompile(`^Nexus/(\S+) \((\w+)\)`)
+
+// Status is what the Source fetched from one Nexus instance. License is only set on editions with a
+// license API and Usage only on the Community Edition, which enforces usage limits instead.
+type Status struct {
+	Edition string
+	Version string
+	License *License
+	Usage   []UsageMetric
+}
+
+// UsageMetric is one usage metric of a Nexus Community Edition instance, e.g. component_total_count
+// or peak_requests_per_day, with the soft and hard thresholds it is limited by
+type UsageMetric struct {
+	Key        string           `json:"metricKey"`
+	Name       string           `json:"metricName"`
+	Value      float64          `json:"metricValue"`
+	Thresholds []UsageThreshold `json:"thresholds"`
+}
+
+// UsageThreshold is one limit of a usage metric, e.g. SOFT_THRESHOLD or HARD_THRESHOLD
+type UsageThreshold struct {
+	Name  string  `json:"thresholdName"`
+	Value float64 `json:"thresholdValue"`
+}
+
+// Limit returns the threshold name without its suffix, e.g. "hard"
+func (t UsageThreshold) Limit() string {
+	return strings.ToLower(strings.TrimSuffix(t.Name, "_THRESHOLD"))
+}
+
+// Utilization returns the value as a ratio of the hard threshold, or of the highest threshold when
+// there is no hard one, and false when the metric has no thresholds
+func (m UsageMetric) Utilization() (float64, bool) {
+	var limit float64
+	for _, t := range m.Thresholds {
+		if t.Limit() == "hard" {
+			limit = t.Value
+			break
+		}
+		if t.Value > limit {
+			limit = t.Value
+		}
+	}
+	if limit <= 0 {
+		return 0, false
+	}
+	return m.Value / limit, true
+}
+
+// systemInformation is the part of the system information API that identifies the Nexus instance
+type systemInformation struct {
+	Status struct {
+		Edition string `json:"edition"`
+		Version string `json:"version"`
+	} `json:"nexus-status"`
+}
+
+// GetEdition returns the edition and version of Nexus from the system information API, which needs the
+// nx-atlas-all privilege. Without it the Server header of the status API is used, unless a proxy strips
+// it, in which case the edition is unknown and an error is returned rather than a guess.
+func GetEdition(ctx context.Context, client *http.Client, config Config) (string, string, error) {
+	info, infoErr := getSystemInformation(ctx, client, config)
+	if infoErr == nil && info.Status.Edition != "" {
+		return strings.ToLower(info.Status.Edition), info.Status.Version, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/service/rest/v1/status", config.URL), nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+
+	match := serverHeader.FindStringSubmatch(resp.Header.Get("Server"))
+	if match == nil {
+		if infoErr == nil {
+			infoErr = source.WithReason(source.ReasonDecode, errors.New("system information has no edition"))
+		}
+		return "", "", fmt.Errorf("unknown Nexus edition, the Server header is not passed through and the system information API failed: %w", infoErr)
+	}
+	return strings.ToLower(match[2]), match[1], nil
+}
+
+// getSystemInformation fetches the system information of Nexus
+func getSystemInformation(ctx context.Context, client *http.Client, config Config) (*systemInformation, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/service/rest/atlas/system-information", config.URL), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+
+	var info systemInformation
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, source.WithReason(source.ReasonDecode, err)
+	}
+	return &info, nil
+}
+
+// GetUsage fetches the usage metrics and limits of a Nexus Community Edition instance. There is no
+// documented API for them, they are read from the internal API behind the Usage page of the UI, which
+// may change between Nexus versions and needs a user that can open that page.
+func GetUsage(ctx context.Context, client *http.Client, config Config) ([]UsageMetric, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/service/rest/internal/ui/usage-metrics", config.URL), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, source.WithReason(source.ReasonAuth, fmt.Errorf("status code %d from the internal usage metrics API, usage is only shown to administrators such as the nx-admin role", resp.StatusCode))
+	case http.StatusNotFound:
+		return nil, source.WithReason(source.ReasonHTTPStatus, errors.New("the internal usage metrics API is not available in this Nexus version"))
+	default:
+		return nil, source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []UsageMetric
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return nil, source.WithReason(source.ReasonDecode, err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,121 @@
+package nexus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gauravkr19/prometheus-exporters/source"
+)
+
+func TestUsageMetricUtilization(t *testing.T) {
+	soft := UsageThreshold{Name: "SOFT_THRESHOLD", Value: 75000}
+	hard := UsageThreshold{Name: "HARD_THRESHOLD", Value: 100000}
+
+	tests := []struct {
+		name       string
+		metric     UsageMetric
+		want       float64
+		wantLimits bool
+	}{
+		{name: "hard threshold", metric: UsageMetric{Value: 50000, Thresholds: []UsageThreshold{soft, hard}}, want: 0.5, wantLimits: true},
+		{name: "hard threshold listed first", metric: UsageMetric{Value: 50000, Thresholds: []UsageThreshold{hard, soft}}, want: 0.5, wantLimits: true},
+		{name: "highest threshold without a hard one", metric: UsageMetric{Value: 60000, Thresholds: []UsageThreshold{soft, {Name: "WARNING_THRESHOLD", Value: 50000}}}, want: 0.8, wantLimits: true},
+		{name: "over the limit", metric: UsageMetric{Value: 150000, Thresholds: []UsageThreshold{hard}}, want: 1.5, wantLimits: true},
+		{name: "no thresholds", metric: UsageMetric{Value: 50000}},
+		{name: "zero threshold", metric: UsageMetric{Value: 50000, Thresholds: []UsageThreshold{{Name: "HARD_THRESHOLD"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.metric.Utilization()
+			if ok != tt.wantLimits || got != tt.want {
+				t.Errorf("Utilization() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantLimits)
+			}
+		})
+	}
+}
+
+func TestUsageThresholdLimit(t *testing.T) {
+	for name, want := range map[string]string{"HARD_THRESHOLD": "hard", "SOFT_THRESHOLD": "soft", "CUSTOM": "custom"} {
+		if got := (UsageThreshold{Name: name}).Limit(); got != want {
+			t.Errorf("Limit() of %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGetEdition(t *testing.T) {
+	tests := []struct {
+		name        string
+		information int
+		body        string
+		server      string
+		wantEdition string
+		wantVersion string
+		wantReason  string
+	}{
+		{name: "system information", information: http.StatusOK, body: `{"nexus-status":{"edition":"PRO","version":"3.77.1-01"}}`, server: "Nexus/3.77.1-01 (COMMUNITY)", wantEdition: EditionPro, wantVersion: "3.77.1-01"},
+		{name: "Server header without the privilege", information: http.StatusForbidden, server: "Nexus/3.77.1-01 (COMMUNITY)", wantEdition: EditionCommunity, wantVersion: "3.77.1-01"},
+		{name: "Server header without an edition in system information", information: http.StatusOK, body: `{}`, server: "Nexus/3.61.0-02 (OSS)", wantEdition: EditionOSS, wantVersion: "3.61.0-02"},
+		{name: "unknown without the privilege", information: http.StatusForbidden, wantReason: source.ReasonAuth},
+		{name: "unknown without an edition", information: http.StatusOK, body: `{}`, wantReason: source.ReasonDecode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/service/rest/atlas/system-information":
+					w.WriteHeader(tt.information)
+					w.Write([]byte(tt.body))
+				case "/service/rest/v1/status":
+					if tt.server != "" {
+						w.Header().Set("Server", tt.server)
+					}
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			edition, version, err := GetEdition(context.Background(), server.Client(), Config{URL: server.URL})
+			if tt.wantReason != "" {
+				if err == nil || source.ReasonOf(err) != tt.wantReason {
+					t.Fatalf("GetEdition() error = %v, want reason %s", err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if edition != tt.wantEdition || version != tt.wantVersion {
+				t.Errorf("GetEdition() = %q, %q, want %q, %q", edition, version, tt.wantEdition, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestGetUsageStatus(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantReason string
+		wantErr    string
+	}{
+		{status: http.StatusUnauthorized, wantReason: source.ReasonAuth, wantErr: "administrators"},
+		{status: http.StatusForbidden, wantReason: source.ReasonAuth, wantErr: "administrators"},
+		{status: http.StatusNotFound, wantReason: source.ReasonHTTPStatus, wantErr: "not available"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			_, err := GetUsage(context.Background(), server.Client(), Config{URL: server.URL})
+			if err == nil || source.ReasonOf(err) != tt.wantReason || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetUsage() error = %v, want reason %s mentioning %q", err, tt.wantReason, tt.wantErr)
+			}
+		})
+	}
+}
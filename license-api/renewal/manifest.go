@@ -0,0 +1,191 @@
+package renewal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/secretstore"
+	"github.com/gauravkr19/prometheus-exporters/vault"
+	"gopkg.in/yaml.v2"
+)
+
+const layout = "2006-01-02"
+
+// Manifest declares a new license key for one instance. ExpiresAt is the expiry the key was issued
+// with, in 2006-01-02 format, and Quantity optionally the users (lines of code for Sonar) it licenses.
+// The key is read from KeyFile, relative to the manifest directory, or given inline as Key or
+// KeyBase64 for binary license files.
+type Manifest struct {
+	Vendor    string `yaml:"vendor"`
+	Instance  string `yaml:"instance"`
+	ExpiresAt string `yaml:"expires_at"`
+	Quantity  int    `yaml:"quantity"`
+	KeyFile   string `yaml:"key_file"`
+	Key       string `yaml:"key"`
+	KeyBase64 string `yaml:"key_base64"`
+}
+
+// License is a license key ready to be applied, Origin is the file or Vault path it was found at
+type License struct {
+	Vendor    string
+	Instance  string
+	ExpiresAt time.Time
+	Quantity  int
+	Key       []byte
+	Origin    string
+}
+
+// target returns the vendor/instance key of the source the license is applied to
+func (l License) target() string {
+	return l.Vendor + "/" + l.Instance
+}
+
+// id identifies the license, so a key is only handled once however often it is found
+func (l License) id() [sha256.Size]byte {
+	return sha256.Sum256(append([]byte(l.target()+"\x00"+l.ExpiresAt.Format(layout)+"\x00"), l.Key...))
+}
+
+// resolve checks the manifest and reads its key, dir is the directory relative key files are read from
+func (m Manifest) resolve(origin, dir string) (License, error) {
+	var errs []error
+	if m.Vendor == "" {
+		errs = append(errs, errors.New("vendor: must not be empty"))
+	}
+	if m.Instance == "" {
+		errs = append(errs, errors.New("instance: must not be empty"))
+	}
+	expiresAt, err := time.Parse(layout, m.ExpiresAt)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("expires_at: %w", err))
+	}
+	if m.Quantity < 0 {
+		errs = append(errs, fmt.Errorf("quantity: must not be negative, got %d", m.Quantity))
+	}
+
+	var key []byte
+	switch {
+	case m.KeyFile != "" && m.Key == "" && m.KeyBase64 == "":
+		path := m.KeyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if key, err = os.ReadFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("key_file: %w", err))
+		}
+	case m.Key != "" && m.KeyFile == "" && m.KeyBase64 == "":
+		key = []byte(m.Key)
+	case m.KeyBase64 != "" && m.KeyFile == "" && m.Key == "":
+		if key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(m.KeyBase64)); err != nil {
+			errs = append(errs, fmt.Errorf("key_base64: %w", err))
+		}
+	default:
+		errs = append(errs, errors.New("exactly one of key_file, key and key_base64 must be set"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return License{}, fmt.Errorf("invalid license manifest %s: %w", origin, err)
+	}
+	return License{Vendor: m.Vendor, Instance: m.Instance, ExpiresAt: expiresAt, Quantity: m.Quantity, Key: key, Origin: origin}, nil
+}
+
+// finder lists the license keys staged in one location
+type finder interface {
+	find(ctx context.Context) ([]License, error)
+}
+
+// directory finds the YAML manifests in a local directory, such as a mounted Kubernetes secret
+type directory struct {
+	path string
+}
+
+// find reads every *.yaml and *.yml manifest in the directory. Invalid manifests are reported
+// in the returned error without hiding the valid ones.
+func (d directory) find(ctx context.Context) ([]License, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading license directory: %w", err)
+	}
+
+	var licenses []License
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		// Kubernetes mounts secrets through hidden ..data directories and symlinks, only regular names are read
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(d.path, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading license manifest: %w", err))
+			continue
+		}
+		var manifest Manifest
+		if err := yaml.UnmarshalStrict(data, &manifest); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing license manifest %s: %w", path, err))
+			continue
+		}
+		license, err := manifest.resolve(path, d.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		licenses = append(licenses, license)
+	}
+	return licenses, errors.Join(errs...)
+}
+
+// vaultSecret finds the license manifest held in the fields of a Vault KV v2 secret
+type vaultSecret struct {
+	path  string
+	store secretstore.Store
+}
+
+// newVaultSecret returns a finder for the secret at path, read with the shared session
+func newVaultSecret(path string, session *vault.Session) vaultSecret {
+	return vaultSecret{path: path, store: secretstore.NewVaultKV2(session.Client(), path, session.Login)}
+}
+
+// find reads the secret, which holds no license while its fields are empty
+func (v vaultSecret) find(ctx context.Context) ([]License, error) {
+	data, err := v.store.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(name string) string {
+		if value, ok := data[name]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+		return ""
+	}
+	manifest := Manifest{
+		Vendor:    field("vendor"),
+		Instance:  field("instance"),
+		ExpiresAt: field("expires_at"),
+		Key:       field("key"),
+		KeyBase64: field("key_base64"),
+	}
+	if manifest == (Manifest{}) {
+		return nil, nil
+	}
+	if quantity := field("quantity"); quantity != "" {
+		var err error
+		if manifest.Quantity, err = strconv.Atoi(quantity); err != nil {
+			return nil, fmt.Errorf("invalid license manifest %s: quantity: %w", v.path, err)
+		}
+	}
+	license, err := manifest.resolve(v.path, "")
+	if err != nil {
+		return nil, err
+	}
+	return []License{license}, nil
+}
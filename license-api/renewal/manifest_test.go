@@ -0,0 +1,72 @@
+package renewal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestResolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gitlab.key"), []byte("file key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	valid := Manifest{Vendor: "gitlab", Instance: "main", ExpiresAt: "2027-10-01", Quantity: 500}
+	with := func(change func(m *Manifest)) Manifest {
+		m := valid
+		change(&m)
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		manifest Manifest
+		wantKey  string
+		// wantErrs are substrings of the expected error, none when the manifest is valid
+		wantErrs []string
+	}{
+		{name: "inline key", manifest: with(func(m *Manifest) { m.Key = "inline key" }), wantKey: "inline key"},
+		{name: "base64 key", manifest: with(func(m *Manifest) { m.KeyBase64 = "YmluYXJ5IGtleQ==\n" }), wantKey: "binary key"},
+		{name: "relative key file", manifest: with(func(m *Manifest) { m.KeyFile = "gitlab.key" }), wantKey: "file key"},
+		{name: "absolute key file", manifest: with(func(m *Manifest) { m.KeyFile = filepath.Join(dir, "gitlab.key") }), wantKey: "file key"},
+		{name: "missing key file", manifest: with(func(m *Manifest) { m.KeyFile = "missing.key" }), wantErrs: []string{"key_file"}},
+		{name: "invalid base64", manifest: with(func(m *Manifest) { m.KeyBase64 = "not base64!" }), wantErrs: []string{"key_base64"}},
+		{name: "no key", manifest: valid, wantErrs: []string{"exactly one of key_file, key and key_base64"}},
+		{name: "two keys", manifest: with(func(m *Manifest) { m.Key, m.KeyFile = "inline key", "gitlab.key" }), wantErrs: []string{"exactly one of key_file, key and key_base64"}},
+		{
+			name:     "every problem is reported",
+			manifest: Manifest{ExpiresAt: "01/10/2027", Quantity: -1, Key: "inline key"},
+			wantErrs: []string{"vendor: must not be empty", "instance: must not be empty", "expires_at", "quantity: must not be negative"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			license, err := tt.manifest.resolve("manifest.yaml", dir)
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("resolve() succeeded, want %q", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("resolve() error = %v, want %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			if string(license.Key) != tt.wantKey {
+				t.Errorf("Key = %q, want %q", license.Key, tt.wantKey)
+			}
+			if want := time.Date(2027, 10, 1, 0, 0, 0, 0, time.UTC); !license.ExpiresAt.Equal(want) || license.Quantity != 500 {
+				t.Errorf("resolve() = expiry %s, quantity %d, want %s, 500", license.ExpiresAt, license.Quantity, want)
+			}
+			if license.target() != "gitlab/main" || license.Origin != "manifest.yaml" {
+				t.Errorf("resolve() = target %q, origin %q", license.target(), license.Origin)
+			}
+		})
+	}
+}
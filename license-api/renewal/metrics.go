@@ -0,0 +1,49 @@
+package renewal
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Prometheus metrics
+var (
+	appliedMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "license_renewal_applied_total",
+			Help: "License keys applied to the source",
+		},
+		[]string{"source", "instance"},
+	)
+	refusedMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "license_renewal_refused_total",
+			Help: "License keys refused because they do not expire after the current license",
+		},
+		[]string{"source", "instance"},
+	)
+	failuresMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "license_renewal_failures_total",
+			Help: "Failed license key applications by reason, retried on the next check unless the reason is license_mismatch",
+		},
+		[]string{"source", "instance", "reason"},
+	)
+	checkFailuresMetric = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "license_renewal_check_failures_total",
+			Help: "Failed reads of the license directory and Vault paths, including invalid manifests",
+		},
+	)
+	lastCheckMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "license_renewal_last_check_timestamp_seconds",
+			Help: "Unix timestamp of the last check for new license keys",
+		},
+	)
+)
+
+func init() {
+	// Register metrics with Prometheus
+	prometheus.MustRegister(appliedMetric)
+	prometheus.MustRegister(refusedMetric)
+	prometheus.MustRegister(failuresMetric)
+	prometheus.MustRegister(checkFailuresMetric)
+	prometheus.MustRegister(lastCheckMetric)
+}
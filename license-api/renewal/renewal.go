@@ -0,0 +1,187 @@
+package renewal
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/gauravkr19/prometheus-exporters/vault"
+)
+
+// Target is a license source new license keys can be applied to
+type Target interface {
+	source.Source
+
+	// ApplyLicense installs the license key declared with the expiry and quantity of declared, current
+	// is the license it replaces, nil when none is installed. It fails with source.ReasonDowngrade when
+	// the key decodes as no improvement on current, and with source.ReasonMismatch when the terms of the
+	// key, or of the license the product reports once it is applied, differ from declared.
+	ApplyLicense(ctx context.Context, key []byte, declared, current *source.Result) error
+}
+
+// Manager applies the license keys staged in the configured directory and Vault paths to their
+// target sources. A key is only applied when it expires after the license the source reports,
+// or on the same day with a larger declared quantity, such as a true-up adding users, and on
+// sources without a license.
+type Manager struct {
+	cfg          config.LicenseRenewal
+	targets      map[string]Target
+	finders      []finder
+	auditor      *audit.Auditor
+	fetchTimeout time.Duration
+
+	// handled holds the keys applied, refused or dry run since startup, failed keys are retried
+	handled map[[sha256.Size]byte]bool
+}
+
+// NewManager returns a manager applying license keys to targets, each given fetchTimeout per key.
+// Vault paths are read with the shared session, which may be nil when only a directory is used.
+func NewManager(cfg config.LicenseRenewal, targets []Target, session *vault.Session, auditor *audit.Auditor, fetchTimeout time.Duration) *Manager {
+	m := &Manager{
+		cfg:          cfg,
+		targets:      make(map[string]Target),
+		auditor:      auditor,
+		fetchTimeout: fetchTimeout,
+		handled:      make(map[[sha256.Size]byte]bool),
+	}
+	for _, target := range targets {
+		m.targets[target.Name()+"/"+target.Instance()] = target
+	}
+	if cfg.Directory != "" {
+		m.finders = append(m.finders, directory{path: cfg.Directory})
+	}
+	for _, path := range cfg.VaultPaths {
+		m.finders = append(m.finders, newVaultSecret(path, session))
+	}
+	return m
+}
+
+// Run checks for new license keys every interval until ctx is done
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check applies every license key found that was not handled before
+func (m *Manager) check(ctx context.Context) {
+	for _, f := range m.finders {
+		findCtx, cancel := context.WithTimeout(ctx, m.fetchTimeout)
+		licenses, err := f.find(findCtx)
+		cancel()
+		if err != nil {
+			checkFailuresMetric.Inc()
+			log.Printf("Failed to check for new license keys: %v", err)
+		}
+
+		for _, license := range licenses {
+			if m.handled[license.id()] {
+				continue
+			}
+			renewCtx, cancel := context.WithTimeout(ctx, m.fetchTimeout)
+			m.handled[license.id()] = m.renew(renewCtx, license)
+			cancel()
+		}
+	}
+	lastCheckMetric.SetToCurrentTime()
+}
+
+// renew applies the license key when it improves on the current license and reports whether the
+// key was handled. A key is installed on an instance without a license. Failed keys are retried on
+// the next check, keys applied with other terms than declared are not.
+func (m *Manager) renew(ctx context.Context, license License) bool {
+	target, ok := m.targets[license.target()]
+	if !ok {
+		m.fail(license, "unknown_target", "current", fmt.Errorf("no %s instance named %s is configured", license.Vendor, license.Instance))
+		return false
+	}
+
+	result, err := target.Fetch(ctx)
+	if err != nil && source.ReasonOf(err) == source.ReasonNoLicense {
+		detail := fmt.Sprintf("%s expires %s with quantity %d, no license is installed", license.Origin, license.ExpiresAt.Format(layout), license.Quantity)
+		return m.apply(ctx, target, license, nil, detail)
+	}
+	if err != nil {
+		m.fail(license, source.ReasonOf(err), "current", fmt.Errorf("failed to fetch the current license: %w", err))
+		return false
+	}
+	detail := fmt.Sprintf("%s expires %s with quantity %d, current license expires %s with quantity %d",
+		license.Origin, license.ExpiresAt.Format(layout), license.Quantity, result.ExpiresAt.UTC().Format(layout), result.Quantity)
+
+	switch declared, currentDay := license.ExpiresAt, day(result.ExpiresAt); {
+	case declared.Before(currentDay):
+		refusedMetric.WithLabelValues(license.Vendor, license.Instance).Inc()
+		m.record(license, "validate", audit.Refused, detail, errors.New("license key does not expire after the current license"))
+		return true
+	case declared.Equal(currentDay) && license.Quantity <= result.Quantity:
+		// Already applied, e.g. before a restart, or a same-term key without a larger quantity declared
+		m.record(license, "validate", audit.Skipped, detail+", declare a larger quantity to apply a key with the current expiry", nil)
+		return true
+	}
+	return m.apply(ctx, target, license, result, detail)
+}
+
+// apply applies the license key to target, current is nil when no license is installed
+func (m *Manager) apply(ctx context.Context, target Target, license License, current *source.Result, detail string) bool {
+	if m.cfg.DryRun {
+		m.record(license, "apply", audit.DryRun, detail, nil)
+		return true
+	}
+
+	declared := &source.Result{ExpiresAt: license.ExpiresAt, Quantity: license.Quantity}
+	if err := target.ApplyLicense(ctx, license.Key, declared, current); err != nil {
+		switch source.ReasonOf(err) {
+		case source.ReasonDowngrade:
+			refusedMetric.WithLabelValues(license.Vendor, license.Instance).Inc()
+			m.record(license, "apply", audit.Refused, detail, err)
+			return true
+		case source.ReasonMismatch:
+			// The key is applied, retrying it would not change what the product reports
+			m.fail(license, source.ReasonMismatch, "verify", err)
+			return true
+		}
+		m.fail(license, source.ReasonOf(err), "apply", err)
+		return false
+	}
+	appliedMetric.WithLabelValues(license.Vendor, license.Instance).Inc()
+	m.record(license, "apply", audit.OK, detail, nil)
+	return true
+}
+
+// fail counts and records a failed license key application
+func (m *Manager) fail(license License, reason, step string, err error) {
+	failuresMetric.WithLabelValues(license.Vendor, license.Instance, reason).Inc()
+	m.record(license, step, audit.Failed, license.Origin, err)
+}
+
+// record writes a license key application step to the audit trail
+func (m *Manager) record(license License, step, outcome, detail string, err error) {
+	m.auditor.Record(audit.Event{
+		Instance: license.target(),
+		Action:   "license_upload",
+		Step:     step,
+		Outcome:  outcome,
+		Detail:   detail,
+	}, err)
+}
+
+// day returns the UTC date of t, license keys are declared with a day's precision
+func day(t time.Time) time.Time {
+	year, month, d := t.UTC().Date()
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
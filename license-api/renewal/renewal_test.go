@@ -0,0 +1,148 @@
+package renewal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/audit"
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeTarget reports a fixed current license and records the keys applied to it
+type fakeTarget struct {
+	current  *source.Result
+	fetchErr error
+	applyErr error
+	applied  [][]byte
+	// replaced is the current license the last key was applied over
+	replaced *source.Result
+}
+
+func (f *fakeTarget) Describe(chan<- *prometheus.Desc) {}
+func (f *fakeTarget) Collect(chan<- prometheus.Metric) {}
+func (f *fakeTarget) Name() string                     { return "gitlab" }
+func (f *fakeTarget) Instance() string                 { return "main" }
+
+func (f *fakeTarget) Fetch(ctx context.Context) (*source.Result, error) {
+	return f.current, f.fetchErr
+}
+
+func (f *fakeTarget) ApplyLicense(ctx context.Context, key []byte, declared, current *source.Result) error {
+	f.applied = append(f.applied, key)
+	f.replaced = current
+	return f.applyErr
+}
+
+// lastEvent returns the last event of the audit log at path
+func lastEvent(t *testing.T, path string) audit.Event {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var event audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return event
+}
+
+func TestManagerRenew(t *testing.T) {
+	expiry := time.Date(2027, 10, 1, 0, 0, 0, 0, time.UTC)
+	// Keys are declared with a day's precision, the current expiry is compared by its UTC date
+	current := &source.Result{ExpiresAt: expiry.Add(15 * time.Hour), Quantity: 500}
+
+	tests := []struct {
+		name     string
+		license  License
+		dryRun   bool
+		fetchErr error
+		applyErr error
+		// unknown targets the license at an instance that is not configured
+		unknown     bool
+		wantHandled bool
+		wantApplied bool
+		wantStep    string
+		wantOutcome string
+	}{
+		{name: "later expiry is applied", license: License{ExpiresAt: expiry.AddDate(1, 0, 0)}, wantHandled: true, wantApplied: true, wantStep: "apply", wantOutcome: audit.OK},
+		{name: "larger quantity on the same day is applied", license: License{ExpiresAt: expiry, Quantity: 600}, wantHandled: true, wantApplied: true, wantStep: "apply", wantOutcome: audit.OK},
+		{name: "earlier expiry is refused", license: License{ExpiresAt: expiry.AddDate(0, 0, -1), Quantity: 600}, wantHandled: true, wantStep: "validate", wantOutcome: audit.Refused},
+		{name: "same terms are skipped", license: License{ExpiresAt: expiry, Quantity: 500}, wantHandled: true, wantStep: "validate", wantOutcome: audit.Skipped},
+		{name: "same day without a quantity is skipped", license: License{ExpiresAt: expiry}, wantHandled: true, wantStep: "validate", wantOutcome: audit.Skipped},
+		{name: "dry run", license: License{ExpiresAt: expiry.AddDate(1, 0, 0)}, dryRun: true, wantHandled: true, wantStep: "apply", wantOutcome: audit.DryRun},
+		{name: "unknown target is retried", license: License{ExpiresAt: expiry.AddDate(1, 0, 0)}, unknown: true, wantStep: "current", wantOutcome: audit.Failed},
+		{name: "failed fetch is retried", license: License{ExpiresAt: expiry.AddDate(1, 0, 0)}, fetchErr: errors.New("gitlab down"), wantStep: "current", wantOutcome: audit.Failed},
+		{name: "unauthorized fetch is retried", license: License{ExpiresAt: expiry.AddDate(1, 0, 0)}, fetchErr: source.WithReason(source.ReasonAuth, errors.New("401 Unauthorized")), wantStep: "current", wantOutcome: audit.Failed},
+		{
+			name:        "key is installed without a license",
+			license:     License{ExpiresAt: expiry.AddDate(0, 0, -1)},
+			fetchErr:    source.WithReason(source.ReasonNoLicense, errors.New("no license is installed")),
+			wantHandled: true, wantApplied: true, wantStep: "apply", wantOutcome: audit.OK,
+		},
+		{
+			name:        "dry run without a license",
+			license:     License{ExpiresAt: expiry},
+			dryRun:      true,
+			fetchErr:    source.WithReason(source.ReasonNoLicense, errors.New("no license is installed")),
+			wantHandled: true, wantStep: "apply", wantOutcome: audit.DryRun,
+		},
+		{
+			name:        "downgrade is refused",
+			license:     License{ExpiresAt: expiry.AddDate(1, 0, 0)},
+			applyErr:    source.WithReason(source.ReasonDowngrade, errors.New("key decodes to an earlier expiry")),
+			wantHandled: true, wantApplied: true, wantStep: "apply", wantOutcome: audit.Refused,
+		},
+		{
+			name:        "mismatch is not retried",
+			license:     License{ExpiresAt: expiry.AddDate(1, 0, 0)},
+			applyErr:    source.WithReason(source.ReasonMismatch, errors.New("license expires 2027-10-01")),
+			wantHandled: true, wantApplied: true, wantStep: "verify", wantOutcome: audit.Failed,
+		},
+		{
+			name:        "failed apply is retried",
+			license:     License{ExpiresAt: expiry.AddDate(1, 0, 0)},
+			applyErr:    source.WithReason(source.ReasonHTTPStatus, errors.New("500 Internal Server Error")),
+			wantApplied: true, wantStep: "apply", wantOutcome: audit.Failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLog := filepath.Join(t.TempDir(), "audit.log")
+			target := &fakeTarget{current: current, fetchErr: tt.fetchErr, applyErr: tt.applyErr}
+			m := NewManager(config.LicenseRenewal{DryRun: tt.dryRun}, []Target{target}, nil, audit.New(auditLog), time.Second)
+
+			license := tt.license
+			license.Vendor, license.Instance, license.Key, license.Origin = "gitlab", "main", []byte("key"), "gitlab.yaml"
+			if tt.unknown {
+				license.Instance = "other"
+			}
+
+			if handled := m.renew(context.Background(), license); handled != tt.wantHandled {
+				t.Errorf("renew() = %v, want %v", handled, tt.wantHandled)
+			}
+			if applied := len(target.applied) > 0; applied != tt.wantApplied {
+				t.Errorf("key applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if wantReplaced := tt.fetchErr == nil; tt.wantApplied && (target.replaced != nil) != wantReplaced {
+				t.Errorf("key applied over %v, want the current license only when one is installed", target.replaced)
+			}
+			if event := lastEvent(t, auditLog); event.Step != tt.wantStep || event.Outcome != tt.wantOutcome {
+				t.Errorf("audit event = %s %s, want %s %s", event.Step, event.Outcome, tt.wantStep, tt.wantOutcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File stores the secret as a JSON object in a local file, readable only by the exporter
+type File struct {
+	path string
+}
+
+// NewFile returns a store backed by the JSON file at path
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Read decodes the JSON file.
+func (f *File) Read(ctx context.Context) (map[string]interface{}, error) {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret file: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("error decoding secret file %s: %w", f.path, err)
+	}
+	return data, nil
+}
+
+// Write replaces the file atomically so a crash never leaves a partially written secret.
+func (f *File) Write(ctx context.Context, data map[string]interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing secret file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing secret file: %w", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing secret file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing secret file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("error writing secret file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// In-cluster service account files mounted into every pod
+const (
+	serviceAccountDir       = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceAccountToken     = serviceAccountDir + "/token"
+	serviceAccountCA        = serviceAccountDir + "/ca.crt"
+	serviceAccountNamespace = serviceAccountDir + "/namespace"
+)
+
+// Kubernetes stores the secret in a Kubernetes Secret through the API server, using the
+// pod's service account. Every value is stored as a string key of the Secret. The service
+// account needs get and patch on the Secret, and create on secrets unless it already exists.
+type Kubernetes struct {
+	client    *http.Client
+	url       string
+	namespace string
+	name      string
+	// tokenFile is re-read on every request, the kubelet rotates the projected token
+	tokenFile string
+}
+
+// NewKubernetes returns an in-cluster store for the Secret name in namespace, which
+// defaults to the pod's own namespace when empty
+func NewKubernetes(namespace, name string) (*Kubernetes, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("kubernetes secret store requires running in a cluster: KUBERNETES_SERVICE_HOST/PORT not set")
+	}
+
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("error reading service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	ca, err := os.ReadFile(serviceAccountCA)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", serviceAccountCA)
+	}
+
+	return &Kubernetes{
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		url:       fmt.Sprintf("https://%s/api/v1/namespaces/%s/secrets/%s", net.JoinHostPort(host, port), namespace, name),
+		namespace: namespace,
+		name:      name,
+		tokenFile: serviceAccountToken,
+	}, nil
+}
+
+// Read returns the decoded keys of the Secret.
+func (k *Kubernetes) Read(ctx context.Context) (map[string]interface{}, error) {
+	body, err := k.do(ctx, http.MethodGet, k.url, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("error decoding secret %s/%s: %w", k.namespace, k.name, err)
+	}
+
+	data := make(map[string]interface{}, len(secret.Data))
+	for key, value := range secret.Data {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding key %s of secret %s/%s: %w", key, k.namespace, k.name, err)
+		}
+		data[key] = string(decoded)
+	}
+	return data, nil
+}
+
+// Write merges data into the Secret, creating the Secret when it does not exist yet.
+func (k *Kubernetes) Write(ctx context.Context, data map[string]interface{}) error {
+	stringData := make(map[string]string, len(data))
+	for key, value := range data {
+		stringData[key] = fmt.Sprint(value)
+	}
+	patch, err := json.Marshal(map[string]interface{}{"stringData": stringData})
+	if err != nil {
+		return err
+	}
+
+	_, err = k.do(ctx, http.MethodPatch, k.url, "application/merge-patch+json", patch)
+	var status *statusError
+	if !errors.As(err, &status) || status.code != http.StatusNotFound {
+		return err
+	}
+
+	secret, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]string{"name": k.name, "namespace": k.namespace},
+		"type":       "Opaque",
+		"stringData": stringData,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k.do(ctx, http.MethodPost, strings.TrimSuffix(k.url, "/"+k.name), "application/json", secret)
+	return err
+}
+
+// statusError is an unsuccessful response of the API server
+type statusError struct {
+	code      int
+	namespace string
+	name      string
+	body      []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d for secret %s/%s: %s", e.code, e.namespace, e.name, e.body)
+}
+
+// do sends a request for the Secret, or to create it, with the current service account token
+func (k *Kubernetes) do(ctx context.Context, method, url, contentType string, body []byte) ([]byte, error) {
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, &statusError{code: resp.StatusCode, namespace: k.namespace, name: k.name, body: respBody}
+	}
+	return respBody, nil
+}
@@ -0,0 +1,50 @@
+package secretstore
+
+import (
+	"context"
+	"sync"
+)
+
+// Store loads and persists the key/value data of a single secret, such as the GitLab rotation token.
+// Values read back may be strings even when written as numbers or booleans, depending on the backend.
+type Store interface {
+	// Read returns the current data of the secret.
+	Read(ctx context.Context) (map[string]interface{}, error)
+
+	// Write replaces the data of the secret.
+	Write(ctx context.Context, data map[string]interface{}) error
+}
+
+// Memory is a Store kept in memory, used by tests and dry runs
+type Memory struct {
+	mu   sync.Mutex
+	data map[string]interface{}
+}
+
+// NewMemory returns a Memory store holding a copy of data
+func NewMemory(data map[string]interface{}) *Memory {
+	return &Memory{data: copyData(data)}
+}
+
+// Read returns a copy of the stored data.
+func (m *Memory) Read(ctx context.Context) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyData(m.data), nil
+}
+
+// Write replaces the stored data with a copy of data.
+func (m *Memory) Write(ctx context.Context, data map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = copyData(data)
+	return nil
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,260 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// fakeVault serves the logical read and write API of Vault, keeping each written body per path
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]json.RawMessage
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "vault-token" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		secret, ok := f.secrets[path]
+		if !ok {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"data":%s}`, secret)
+	case http.MethodPut, http.MethodPost:
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.secrets[path] = body
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeKubernetes serves the Secret API for the Secret monitoring/gitlab-token, merging the stringData of
+// patches into its data. The Secret does not exist until it is created, data is nil until then.
+type fakeKubernetes struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (f *fakeKubernetes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer sa-token" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var stringData map[string]string
+	switch {
+	case r.URL.Path == "/api/v1/namespaces/monitoring/secrets" && r.Method == http.MethodPost:
+		var secret struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			StringData map[string]string `json:"stringData"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&secret); err != nil || secret.Metadata.Name != "gitlab-token" || secret.Metadata.Namespace != "monitoring" {
+			http.Error(w, "invalid secret", http.StatusUnprocessableEntity)
+			return
+		}
+		if f.data != nil {
+			http.Error(w, "already exists", http.StatusConflict)
+			return
+		}
+		f.data = make(map[string]string)
+		stringData = secret.StringData
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path != "/api/v1/namespaces/monitoring/secrets/gitlab-token" || f.data == nil:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	case r.Method == http.MethodGet:
+	case r.Method == http.MethodPatch:
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			http.Error(w, "unsupported patch type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var patch struct {
+			StringData map[string]string `json:"stringData"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stringData = patch.StringData
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	for key, value := range stringData {
+		f.data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": f.data})
+}
+
+// newVaultClient returns a Vault client for the fake server, login counts the logins of the stores
+func newVaultClient(t *testing.T, logins *int) (*api.Client, LoginFunc) {
+	t.Helper()
+	server := httptest.NewServer(&fakeVault{secrets: make(map[string]json.RawMessage)})
+	t.Cleanup(server.Close)
+
+	cfg := api.DefaultConfig()
+	cfg.Address = server.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ClearToken()
+	return client, func(ctx context.Context, client *api.Client) error {
+		*logins++
+		client.SetToken("vault-token")
+		return nil
+	}
+}
+
+// newKubernetesStore returns a store for the Secret monitoring/gitlab-token of a fake API server,
+// which is created by the first write
+func newKubernetesStore(t *testing.T) *Kubernetes {
+	t.Helper()
+	server := httptest.NewTLSServer(&fakeKubernetes{})
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &Kubernetes{
+		client:    server.Client(),
+		url:       server.URL + "/api/v1/namespaces/monitoring/secrets/gitlab-token",
+		namespace: "monitoring",
+		name:      "gitlab-token",
+		tokenFile: tokenFile,
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	var logins int
+	vaultClient, login := newVaultClient(t, &logins)
+
+	tests := []struct {
+		name       string
+		store      Store
+		wantLogins int
+	}{
+		{name: "memory", store: NewMemory(nil)},
+		{name: "file", store: NewFile(filepath.Join(t.TempDir(), "token.json"))},
+		{name: "vault kv2", store: NewVaultKV2(vaultClient, "secret/data/gitlab", login), wantLogins: 4},
+		{name: "vault kv1", store: NewVaultKV1(vaultClient, "secret/gitlab", login), wantLogins: 4},
+		{name: "kubernetes", store: newKubernetesStore(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			logins = 0
+			for _, data := range []map[string]interface{}{
+				{"id": 42, "expires_at": "2026-12-31", "active": true, "token": "glpat-42"},
+				{"id": 43, "expires_at": "2027-03-31", "active": false, "token": "glpat-43"},
+			} {
+				if err := tt.store.Write(ctx, data); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				got, err := tt.store.Read(ctx)
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+				// Backends may return numbers and booleans as strings or JSON numbers
+				for key, want := range data {
+					if fmt.Sprint(got[key]) != fmt.Sprint(want) {
+						t.Errorf("Read()[%s] = %v, want %v", key, got[key], want)
+					}
+				}
+			}
+			if logins != tt.wantLogins {
+				t.Errorf("logged in %d times, want %d", logins, tt.wantLogins)
+			}
+		})
+	}
+}
+
+func TestStoreReadMissing(t *testing.T) {
+	var logins int
+	vaultClient, login := newVaultClient(t, &logins)
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{name: "file", store: NewFile(filepath.Join(t.TempDir(), "missing.json"))},
+		{name: "kubernetes", store: newKubernetesStore(t)},
+		{name: "vault kv2", store: NewVaultKV2(vaultClient, "secret/data/missing", login)},
+		{name: "vault kv1", store: NewVaultKV1(vaultClient, "secret/missing", login)},
+		{name: "vault without login", store: NewVaultKV2(vaultClient, "secret/data/gitlab", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vaultClient.ClearToken()
+			if _, err := tt.store.Read(context.Background()); err == nil {
+				t.Error("Read() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestKubernetesRejectedToken(t *testing.T) {
+	store := newKubernetesStore(t)
+	if err := os.WriteFile(store.tokenFile, []byte("expired-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.Read(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unexpected status code 401") {
+		t.Errorf("Read() error = %v, want status code 401", err)
+	}
+	if err := store.Write(context.Background(), map[string]interface{}{"token": "glpat"}); err == nil {
+		t.Error("Write() succeeded with a rejected token")
+	}
+}
+
+func TestMemoryCopiesData(t *testing.T) {
+	data := map[string]interface{}{"token": "glpat-42"}
+	store := NewMemory(data)
+	data["token"] = "changed"
+
+	got, err := store.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got["token"] = "changed too"
+	if got, _ := store.Read(context.Background()); got["token"] != "glpat-42" {
+		t.Errorf("Read()[token] = %v, want the data the store was created with", got["token"])
+	}
+
+	if err := store.Write(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Read(context.Background()); len(got) != 0 {
+		t.Errorf("Read() = %v after writing no data, want empty", got)
+	}
+}
@@ -0,0 +1,104 @@
+package secretstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// LoginFunc authenticates a Vault client before it is used, it should return quickly when the client already holds a valid token
+type LoginFunc func(ctx context.Context, client *api.Client) error
+
+// VaultKV2 stores the secret in a Vault KV version 2 engine. Path is the full logical
+// path including the data/ segment, e.g. secrets/devops/data/gitlab.
+type VaultKV2 struct {
+	client *api.Client
+	path   string
+	login  LoginFunc
+}
+
+// NewVaultKV2 returns a KV v2 store, login is called before every read and write when set
+func NewVaultKV2(client *api.Client, path string, login LoginFunc) *VaultKV2 {
+	return &VaultKV2{client: client, path: path, login: login}
+}
+
+// Read returns the latest version of the secret.
+func (v *VaultKV2) Read(ctx context.Context) (map[string]interface{}, error) {
+	secret, err := readVault(ctx, v.client, v.path, v.login)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error reading Vault KV2: no data at %s", v.path)
+	}
+	return data, nil
+}
+
+// Write stores data as a new version of the secret.
+func (v *VaultKV2) Write(ctx context.Context, data map[string]interface{}) error {
+	// Wrap data map inside another map with key "data" for KV-v2
+	payload := map[string]interface{}{
+		"data": data,
+	}
+	if err := vaultLogin(ctx, v.client, v.login); err != nil {
+		return err
+	}
+	if _, err := v.client.Logical().WriteWithContext(ctx, v.path, payload); err != nil {
+		return fmt.Errorf("error writing to Vault KV2: %w", err)
+	}
+	return nil
+}
+
+// VaultKV1 stores the secret in a Vault KV version 1 engine
+type VaultKV1 struct {
+	client *api.Client
+	path   string
+	login  LoginFunc
+}
+
+// NewVaultKV1 returns a KV v1 store, login is called before every read and write when set
+func NewVaultKV1(client *api.Client, path string, login LoginFunc) *VaultKV1 {
+	return &VaultKV1{client: client, path: path, login: login}
+}
+
+// Read returns the secret.
+func (v *VaultKV1) Read(ctx context.Context) (map[string]interface{}, error) {
+	return readVault(ctx, v.client, v.path, v.login)
+}
+
+// Write replaces the secret with data.
+func (v *VaultKV1) Write(ctx context.Context, data map[string]interface{}) error {
+	if err := vaultLogin(ctx, v.client, v.login); err != nil {
+		return err
+	}
+	if _, err := v.client.Logical().WriteWithContext(ctx, v.path, data); err != nil {
+		return fmt.Errorf("error writing to Vault KV1: %w", err)
+	}
+	return nil
+}
+
+// vaultLogin calls login when set
+func vaultLogin(ctx context.Context, client *api.Client, login LoginFunc) error {
+	if login == nil {
+		return nil
+	}
+	return login(ctx, client)
+}
+
+// readVault logs in when needed and returns the data of the secret at path
+func readVault(ctx context.Context, client *api.Client, path string, login LoginFunc) (map[string]interface{}, error) {
+	if err := vaultLogin(ctx, client, login); err != nil {
+		return nil, err
+	}
+
+	secret, err := client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Vault: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("error reading Vault: no secret at %s", path)
+	}
+	return secret.Data, nil
+}
@@ -2,57 +2,121 @@ package sonar
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Prometheus metrics
-var (
-	licenseMetric = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "sonar_license_info",
-			Help: "sonar License Information",
-		},
-		[]string{
-			"expires_at",
-			"is_expired",
-			"edition",
-			"is_valid_edition",
-			"max_loc",
-			"loc",
-			"is_official_distribution",
-			"is_supported",
-			"remaining_loc_threshold",
-		},
-	)
-	daysUntilExpiryMetric = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "sonar_license_days_until_expiry",
-			Help: "Days until Sonar License expires",
-		},
-	)
-)
+// metrics holds the Prometheus descriptors of the Sonar license metrics for one instance
+type metrics struct {
+	license               *prometheus.Desc
+	loc                   *prometheus.Desc
+	maxLoc                *prometheus.Desc
+	locUtilization        *prometheus.Desc
+	remainingLocThreshold *prometheus.Desc
+	daysUntilExpiry       *prometheus.Desc
+	expiresAt             *prometheus.Desc
+}
+
+// newMetrics creates the metric descriptors with the instance as a constant label
+func newMetrics(instance string) metrics {
+	constLabels := prometheus.Labels{"instance": instance}
+	return metrics{
+		license: prometheus.NewDesc(
+			"sonar_license_info",
+			"Sonar License Information, its expiry is exported as a timestamp gauge so the series does not change on renewal",
+			[]string{
+				"edition",
+				"is_valid_edition",
+				"is_official_distribution",
+				"is_supported",
+			},
+			constLabels,
+		),
+		loc: prometheus.NewDesc(
+			"sonar_license_loc",
+			"Lines of code analysed by Sonar counted against the License",
+			nil,
+			constLabels,
+		),
+		maxLoc: prometheus.NewDesc(
+			"sonar_license_max_loc",
+			"Lines of code allowed by the Sonar License",
+			nil,
+			constLabels,
+		),
+		locUtilization: prometheus.NewDesc(
+			"sonar_license_loc_utilization_ratio",
+			"Ratio of analysed lines of code to the Sonar License limit",
+			nil,
+			constLabels,
+		),
+		remainingLocThreshold: prometheus.NewDesc(
+			"sonar_license_remaining_loc_threshold",
+			"Remaining lines of code below which Sonar warns about the License limit",
+			nil,
+			constLabels,
+		),
+		daysUntilExpiry: prometheus.NewDesc(
+			"sonar_license_days_until_expiry",
+			"Days until Sonar License expires",
+			nil,
+			constLabels,
+		),
+		expiresAt: prometheus.NewDesc(
+			"sonar_license_expiry_timestamp_seconds",
+			"Unix timestamp when the Sonar License expires",
+			nil,
+			constLabels,
+		),
+	}
+}
 
-func init() {
-	// Register metrics with Prometheus
-	prometheus.MustRegister(licenseMetric)
-	prometheus.MustRegister(daysUntilExpiryMetric)
+// Describe sends the Sonar license metric descriptors.
+func (s *Source) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.metrics.license
+	ch <- s.metrics.loc
+	ch <- s.metrics.maxLoc
+	ch <- s.metrics.locUtilization
+	ch <- s.metrics.remainingLocThreshold
+	ch <- s.metrics.daysUntilExpiry
+	ch <- s.metrics.expiresAt
+	s.cache.Describe(ch)
 }
 
-// RegisterMetrics registers license information as Prometheus metrics.
-func RegisterMetrics(license License) {
-	licenseMetric.With(prometheus.Labels{
-		"expires_at":               license.ExpiresAt.String(),
-		"is_expired":               fmt.Sprint(license.IsExpired),
-		"edition":                  license.Edition,
-		"is_valid_edition":         fmt.Sprint(license.IsValidEdition),
-		"max_loc":                  fmt.Sprint(license.MaxLoC),
-		"loc":                      fmt.Sprint(license.LoC),
-		"is_official_distribution": fmt.Sprint(license.IsOfficialDistribution),
-		"is_supported":             fmt.Sprint(license.IsSupported),
-		"remaining_loc_threshold":  fmt.Sprint(license.RemainingLocThreshold),
-	}).Set(1)
+// Collect fetches the license through the cache and sends it as Prometheus metrics.
+func (s *Source) Collect(ch chan<- prometheus.Metric) {
+	result, err := s.cache.Collect(ch)
+	if err != nil {
+		log.Printf("Failed to fetch Sonar license for instance %s: %v", s.instance, err)
+	}
+	if result == nil {
+		return
+	}
+
+	// Days until expiry are computed against the current time, not the fetch time
+	license := NewLicense(result.Details.(License))
+
+	ch <- prometheus.MustNewConstMetric(s.metrics.license, prometheus.GaugeValue, 1,
+		license.Edition,
+		fmt.Sprint(license.IsValidEdition),
+		fmt.Sprint(license.IsOfficialDistribution),
+		fmt.Sprint(license.IsSupported),
+	)
+	ch <- prometheus.MustNewConstMetric(s.metrics.loc, prometheus.GaugeValue, float64(license.LoC))
+	ch <- prometheus.MustNewConstMetric(s.metrics.maxLoc, prometheus.GaugeValue, float64(license.MaxLoC))
+	ch <- prometheus.MustNewConstMetric(s.metrics.remainingLocThreshold, prometheus.GaugeValue, float64(license.RemainingLocThreshold))
+
+	// Editions without a LOC cap report maxLoc as 0, there is no ratio to export then
+	if license.MaxLoC > 0 {
+		ch <- prometheus.MustNewConstMetric(s.metrics.locUtilization, prometheus.GaugeValue, float64(license.LoC)/float64(license.MaxLoC))
+	}
 
 	// Set the days until expiry metric
-	daysUntilExpiryMetric.Set(float64(license.DaysUntilExpiry))
+	ch <- prometheus.MustNewConstMetric(s.metrics.daysUntilExpiry, prometheus.GaugeValue, float64(license.DaysUntilExpiry))
+
+	// Timestamps let PromQL compute the exact time left, including negative values once expired
+	if !license.ExpiresAt.IsZero() {
+		ch <- prometheus.MustNewConstMetric(s.metrics.expiresAt, prometheus.GaugeValue, float64(license.ExpiresAt.Unix()))
+	}
 }
@@ -1,15 +1,18 @@
 package sonar
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/gauravkr19/prometheus-exporters/source"
 )
 
 const layout = "2006-01-02" // As the ExpiresAt format does not comply with time.Time "2006-01-02" format, ie. without time/TZ
@@ -36,44 +39,85 @@ type Config struct {
 	URL      string
 	Username string
 	Password string
-	Insecure bool
+	TLS      config.TLS
 }
 
-// Setupsonar setsup sonar client
-func SetupSonar() (*http.Client, Config) {
+// SetupSonar setsup sonar client
+func SetupSonar(cfg config.Server) (*http.Client, Config, error) {
 	sonarConfig := Config{
-		URL:      os.Getenv("SONAR_URL"),
-		Username: os.Getenv("SONAR_USERNAME"),
-		Password: os.Getenv("SONAR_PASSWORD"),
-		Insecure: true,
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		TLS:      cfg.TLS,
+	}
+	sonarClient, err := NewClient(sonarConfig)
+	if err != nil {
+		return nil, Config{}, err
 	}
-	sonarClient := NewClient(sonarConfig)
-	return sonarClient, sonarConfig
+	return sonarClient, sonarConfig, nil
 }
 
 // NewClient creates a new sonar client
-func NewClient(config Config) *http.Client {
+func NewClient(config Config) (*http.Client, error) {
+	tlsConfig, err := config.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
+		TLSClientConfig: tlsConfig,
 	}
-	return &http.Client{Transport: transport}
+	return &http.Client{Transport: transport}, nil
 }
 
-// UpdateSonarLicense fetches and updates the Sonar license metrics
-func UpdateSonarLicense(client *http.Client, config Config) {
-	license, err := GetLicense(client, config)
+// Source polls the license API of one Sonar instance.
+type Source struct {
+	instance string
+	client   *http.Client
+	config   Config
+	cache    *source.Cache
+	metrics  metrics
+}
+
+// NewSource sets up the Sonar client and returns a Sonar license source, caching fetched licenses for cacheTTL.
+func NewSource(cfg config.Server, cacheTTL, fetchTimeout time.Duration) (*Source, error) {
+	client, clientConfig, err := SetupSonar(cfg)
 	if err != nil {
-		log.Printf("Failed to fetch Sonar license: %v", err)
-		return
+		return nil, err
 	}
-	licenseInfo := NewLicense(license)
+	s := &Source{instance: cfg.Name, client: client, config: clientConfig, metrics: newMetrics(cfg.Name)}
+	s.cache = source.NewCache(s, cacheTTL, fetchTimeout)
+	return s, nil
+}
+
+// Name returns the vendor name of the source.
+func (s *Source) Name() string {
+	return "sonar"
+}
 
-	RegisterMetrics(licenseInfo)
+// Instance returns the configured instance name of the source.
+func (s *Source) Instance() string {
+	return s.instance
+}
+
+// Fetch fetches the Sonar license information
+func (s *Source) Fetch(ctx context.Context) (*source.Result, error) {
+	license, err := GetLicense(ctx, s.client, s.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Sonar license: %w", err)
+	}
+
+	return &source.Result{
+		Plan:      license.Edition,
+		ExpiresAt: license.ExpiresAt.Time,
+		Expired:   license.IsExpired,
+		Quantity:  license.MaxLoC,
+		Details:   license,
+	}, nil
 }
 
 // GetLicense fetches the license information from Sonar
-func GetLicense(client *http.Client, config Config) (License, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/editions/show_license", config.URL), nil)
+func GetLicense(ctx context.Context, client *http.Client, config Config) (License, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/editions/show_license", config.URL), nil)
 	if err != nil {
 		return License{}, err
 	}
@@ -85,8 +129,12 @@ func GetLicense(client *http.Client, config Config) (License, error) {
 	}
 	defer resp.Body.Close()
 
+	// Sonar answers 404 Not Found when no license is set
+	if resp.StatusCode == http.StatusNotFound {
+		return License{}, source.WithReason(source.ReasonNoLicense, errors.New("no license is set"))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return License{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return License{}, source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -96,7 +144,7 @@ func GetLicense(client *http.Client, config Config) (License, error) {
 
 	var license License
 	if err := json.Unmarshal(body, &license); err != nil {
-		return License{}, err
+		return License{}, source.WithReason(source.ReasonDecode, err)
 	}
 
 	// Calculate days until expiry
@@ -105,6 +153,44 @@ func GetLicense(client *http.Client, config Config) (License, error) {
 	return license, nil
 }
 
+// ApplyLicense sets the license key of the Sonar instance. Sonar only reports the new expiry once
+// the key is set, so the caller checks the expiry it was declared with against current and the
+// license Sonar reports afterwards is checked against declared.
+func (s *Source) ApplyLicense(ctx context.Context, key []byte, declared, _ *source.Result) error {
+	if err := SetLicense(ctx, s.client, s.config, strings.TrimSpace(string(key))); err != nil {
+		return fmt.Errorf("failed to set Sonar license: %w", err)
+	}
+	s.cache.Invalidate()
+
+	applied, err := s.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the applied Sonar license: %w", err)
+	}
+	return source.CheckApplied(declared, applied)
+}
+
+// SetLicense sets the license key through the Sonar editions API
+func SetLicense(ctx context.Context, client *http.Client, config Config, key string) error {
+	form := url.Values{"license": {key}}
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/editions/set_license", config.URL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return source.WithReason(source.StatusReason(resp.StatusCode), fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+	return nil
+}
+
 // NewLicense creates a new License instance.
 func NewLicense(license License) License {
 	var daysUntilExpiry int
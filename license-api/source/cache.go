@@ -0,0 +1,115 @@
+package source
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache serves the last fetched result of a Source for a TTL and collapses concurrent
+// fetches into one, so simultaneous scrapes do not stampede the upstream API.
+// It also tracks the fetch health of the source, which the source exports through Collect.
+type Cache struct {
+	source  Source
+	ttl     time.Duration
+	timeout time.Duration
+	group   singleflight.Group
+	health  health
+
+	mu        sync.Mutex
+	result    *Result
+	fetchedAt time.Time
+	// invalid forces the next Get to fetch, the result is kept to be served if that fetch fails
+	invalid  bool
+	up       bool
+	duration time.Duration
+	errors   map[string]float64
+}
+
+// fetched is what one fetch shares with every Get waiting on it
+type fetched struct {
+	result *Result
+	status status
+}
+
+// NewCache returns a Cache that fetches from s at most once per ttl, giving each fetch timeout to complete
+func NewCache(s Source, ttl, timeout time.Duration) *Cache {
+	return &Cache{
+		source:  s,
+		ttl:     ttl,
+		timeout: timeout,
+		health:  newHealth(s.Name(), s.Instance()),
+		errors:  make(map[string]float64),
+	}
+}
+
+// Get returns the cached result while it is fresh, otherwise fetches a new one.
+// When the fetch fails the last good result, if any, is returned along with the error,
+// Collect exports its age so the stale values can be told apart.
+func (c *Cache) Get() (*Result, error) {
+	f, err := c.get()
+	return f.result, err
+}
+
+// Describe sends the descriptors of the fetch health metrics of the source.
+func (c *Cache) Describe(ch chan<- *prometheus.Desc) {
+	c.health.describe(ch)
+}
+
+// Collect gets the result like Get and sends the fetch health metrics of the source as of that result,
+// for the source to call from its own Collect.
+func (c *Cache) Collect(ch chan<- prometheus.Metric) (*Result, error) {
+	f, err := c.get()
+	c.health.collect(ch, f.status)
+	return f.result, err
+}
+
+// get returns the fresh result, or fetches a new one, with the fetch health at that moment
+func (c *Cache) get() (fetched, error) {
+	c.mu.Lock()
+	if c.result != nil && !c.invalid && time.Since(c.fetchedAt) < c.ttl {
+		f := fetched{result: c.result, status: c.status()}
+		c.mu.Unlock()
+		return f, nil
+	}
+	c.mu.Unlock()
+
+	v, err, _ := c.group.Do("fetch", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+
+		start := time.Now()
+		result, err := c.source.Fetch(ctx)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.duration, c.up = time.Since(start), err == nil
+		if err != nil {
+			c.errors[ReasonOf(err)]++
+		} else {
+			c.result, c.fetchedAt, c.invalid = result, time.Now(), false
+		}
+		return fetched{result: c.result, status: c.status()}, err
+	})
+	return v.(fetched), err
+}
+
+// status returns a copy of the fetch health, c.mu must be held
+func (c *Cache) status() status {
+	errors := make(map[string]float64, len(c.errors))
+	for reason, count := range c.errors {
+		errors[reason] = count
+	}
+	return status{up: c.up, lastSuccess: c.fetchedAt, duration: c.duration, errors: errors}
+}
+
+// Invalidate marks the cached result as stale so the next Get fetches again, e.g. after a new license was applied.
+// The result is kept to be served if that fetch fails.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalid = true
+}
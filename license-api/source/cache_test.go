@@ -0,0 +1,232 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeSource returns the results and errors it is given, counting its fetches
+type fakeSource struct {
+	instance string
+	fetches  atomic.Int32
+	// release blocks every fetch until it is closed, when set
+	release chan struct{}
+
+	mu     sync.Mutex
+	result *Result
+	err    error
+}
+
+func (f *fakeSource) Describe(chan<- *prometheus.Desc) {}
+func (f *fakeSource) Collect(chan<- prometheus.Metric) {}
+func (f *fakeSource) Name() string                     { return "fake" }
+func (f *fakeSource) Instance() string                 { return f.instance }
+
+func (f *fakeSource) Fetch(ctx context.Context) (*Result, error) {
+	f.fetches.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.result, f.err
+}
+
+func (f *fakeSource) set(result *Result, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.result, f.err = result, err
+}
+
+func TestCacheGet(t *testing.T) {
+	fresh := &Result{Plan: "fresh"}
+	stale := &Result{Plan: "stale"}
+	upstreamErr := errors.New("upstream down")
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// cached is fetched before the test fetch, nil to start empty
+		cached      *Result
+		invalidate  bool
+		result      *Result
+		err         error
+		want        *Result
+		wantErr     bool
+		wantFetches int32
+	}{
+		{name: "empty cache fetches", ttl: time.Hour, result: fresh, want: fresh, wantFetches: 1},
+		{name: "fresh result is served", ttl: time.Hour, cached: stale, result: fresh, want: stale, wantFetches: 1},
+		{name: "expired result is fetched again", ttl: 0, cached: stale, result: fresh, want: fresh, wantFetches: 2},
+		{name: "invalidated result is fetched again", ttl: time.Hour, cached: stale, invalidate: true, result: fresh, want: fresh, wantFetches: 2},
+		{name: "stale result is served on error", ttl: 0, cached: stale, err: upstreamErr, want: stale, wantErr: true, wantFetches: 2},
+		{name: "nothing is served on a first error", ttl: time.Hour, err: upstreamErr, want: nil, wantErr: true, wantFetches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeSource{}
+			c := NewCache(s, tt.ttl, time.Second)
+			if tt.cached != nil {
+				s.set(tt.cached, nil)
+				if _, err := c.Get(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.invalidate {
+				c.Invalidate()
+			}
+
+			s.set(tt.result, tt.err)
+			got, err := c.Get()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %+v, want %+v", got, tt.want)
+			}
+			if n := s.fetches.Load(); n != tt.wantFetches {
+				t.Errorf("fetched %d times, want %d", n, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestCacheGetCollapsesConcurrentFetches(t *testing.T) {
+	s := &fakeSource{release: make(chan struct{}), result: &Result{Plan: "premium"}}
+	c := NewCache(s, time.Hour, time.Second)
+
+	const scrapes = 10
+	var wg sync.WaitGroup
+	results := make(chan *Result, scrapes)
+	for i := 0; i < scrapes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.Get()
+			if err != nil {
+				t.Error(err)
+			}
+			results <- result
+		}()
+	}
+	// Let every scrape reach the cache before the fetch completes
+	for s.fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+	close(results)
+
+	if n := s.fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times for %d concurrent scrapes, want 1", n, scrapes)
+	}
+	for result := range results {
+		if result == nil || result.Plan != "premium" {
+			t.Errorf("Get() = %+v, want the fetched result", result)
+		}
+	}
+}
+
+// healthCollector exports the fetch health of a cache like a source does from its Collect
+type healthCollector struct {
+	cache *Cache
+}
+
+func (h healthCollector) Describe(ch chan<- *prometheus.Desc) { h.cache.Describe(ch) }
+func (h healthCollector) Collect(ch chan<- prometheus.Metric) { h.cache.Collect(ch) }
+
+// gather scrapes the registry and returns the value of each series by name, instance and reason
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range m.GetLabel() {
+				if label.GetName() != "source" {
+					key += "/" + label.GetValue()
+				}
+			}
+			values[key] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return values
+}
+
+func TestCacheCollectHealth(t *testing.T) {
+	prod, staging := &fakeSource{instance: "prod"}, &fakeSource{instance: "staging"}
+	prodCache, stagingCache := NewCache(prod, 0, time.Second), NewCache(staging, 0, time.Second)
+	registry := prometheus.NewRegistry()
+	// Every source registers its own health series
+	registry.MustRegister(healthCollector{prodCache}, healthCollector{stagingCache})
+
+	prod.set(&Result{Plan: "premium"}, nil)
+	staging.set(nil, WithReason(ReasonAuth, errors.New("401 Unauthorized")))
+	values := gather(t, registry)
+	if values["license_source_up/prod"] != 1 || values["license_source_up/staging"] != 0 {
+		t.Errorf("up = %v, %v, want 1 for prod and 0 for staging", values["license_source_up/prod"], values["license_source_up/staging"])
+	}
+	if values["license_source_last_success_timestamp_seconds/prod"] == 0 {
+		t.Error("last success of prod not exported")
+	}
+	if _, ok := values["license_source_last_success_timestamp_seconds/staging"]; ok {
+		t.Error("last success exported for staging, which never succeeded")
+	}
+	if values["license_source_errors_total/staging/"+ReasonAuth] != 1 {
+		t.Errorf("errors = %v, want one %s error for staging", values, ReasonAuth)
+	}
+
+	// A failure after a success is reported next to the stale result it serves
+	prod.set(nil, context.DeadlineExceeded)
+	values = gather(t, registry)
+	if values["license_source_up/prod"] != 0 || values["license_source_errors_total/prod/"+ReasonTimeout] != 1 {
+		t.Errorf("prod health = %v, want down with one %s error", values, ReasonTimeout)
+	}
+	if values["license_source_last_success_timestamp_seconds/prod"] == 0 {
+		t.Error("last success of prod dropped after a failure")
+	}
+	if values["license_source_errors_total/staging/"+ReasonAuth] != 2 {
+		t.Errorf("errors of staging = %v, want 2", values["license_source_errors_total/staging/"+ReasonAuth])
+	}
+}
+
+func TestCacheServesStaleResultWithItsAge(t *testing.T) {
+	s := &fakeSource{instance: "prod", result: &Result{Plan: "premium"}}
+	c := NewCache(s, time.Minute, time.Second)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(healthCollector{c})
+
+	values := gather(t, registry)
+	if age := values["license_source_result_age_seconds/prod"]; age < 0 || age > 1 {
+		t.Errorf("age of a fresh result = %gs, want about 0", age)
+	}
+
+	// The result is ten minutes old when the source starts failing
+	c.mu.Lock()
+	c.fetchedAt = c.fetchedAt.Add(-10 * time.Minute)
+	c.mu.Unlock()
+	s.set(nil, errors.New("upstream down"))
+
+	result, err := c.Get()
+	if err == nil || result == nil || result.Plan != "premium" {
+		t.Fatalf("Get() = %+v, %v, want the last result with the error", result, err)
+	}
+	values = gather(t, registry)
+	if values["license_source_up/prod"] != 0 {
+		t.Error("up = 1 while the source fails")
+	}
+	if age := values["license_source_result_age_seconds/prod"]; age < 600 || age > 601 {
+		t.Errorf("age of the stale result = %gs, want 600s", age)
+	}
+}
@@ -0,0 +1,95 @@
+package source
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Reasons reported in the reason label of license_source_errors_total
+const (
+	ReasonAuth        = "auth"
+	ReasonConnection  = "connection"
+	ReasonDecode      = "decode"
+	ReasonDowngrade   = "license_downgrade"
+	ReasonHTTPStatus  = "http_status"
+	ReasonMismatch    = "license_mismatch"
+	ReasonNoLicense   = "no_license"
+	ReasonRotation    = "token_rotation"
+	ReasonSecretStore = "secret_store"
+	ReasonTimeout     = "timeout"
+	ReasonTLS         = "tls_verify"
+	ReasonUnknown     = "unknown"
+	ReasonVault       = "vault"
+)
+
+// Error attaches a reason to an error returned from Source.Fetch
+type Error struct {
+	Reason string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithReason wraps err with the given reason, keeping nil errors nil
+func WithReason(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Reason: reason, Err: err}
+}
+
+// ReasonOf returns the reason for err. Certificate verification failures are reported as
+// tls_verify whatever reason is attached, otherwise the attached reason wins over timeout
+// and connection errors detected from the error chain.
+func ReasonOf(err error) string {
+	if isTLSVerifyError(err) {
+		return ReasonTLS
+	}
+
+	var reasonErr *Error
+	if errors.As(err, &reasonErr) {
+		return reasonErr.Reason
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ReasonTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ReasonTimeout
+		}
+		return ReasonConnection
+	}
+	return ReasonUnknown
+}
+
+// isTLSVerifyError reports whether err is caused by a server certificate that failed verification
+func isTLSVerifyError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// StatusReason returns the reason for an unexpected HTTP status code
+func StatusReason(statusCode int) string {
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return ReasonAuth
+	}
+	return ReasonHTTPStatus
+}
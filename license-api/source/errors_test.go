@@ -0,0 +1,52 @@
+package source
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestReasonOf(t *testing.T) {
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	unknownAuthority := &url.Error{Op: "Get", URL: "https://gitlab", Err: x509.UnknownAuthorityError{}}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "attached reason", err: WithReason(ReasonAuth, errors.New("401")), want: ReasonAuth},
+		{name: "wrapped attached reason", err: fmt.Errorf("fetch: %w", WithReason(ReasonDecode, errors.New("bad json"))), want: ReasonDecode},
+		{name: "reason wins over timeout", err: WithReason(ReasonVault, context.DeadlineExceeded), want: ReasonVault},
+		{name: "deadline exceeded", err: fmt.Errorf("fetch: %w", context.DeadlineExceeded), want: ReasonTimeout},
+		{name: "network timeout", err: &url.Error{Op: "Get", URL: "https://nexus", Err: timeoutError{}}, want: ReasonTimeout},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "https://nexus", Err: connRefused}, want: ReasonConnection},
+		{name: "certificate verification", err: unknownAuthority, want: ReasonTLS},
+		{name: "certificate verification wins over reason", err: WithReason(ReasonHTTPStatus, unknownAuthority), want: ReasonTLS},
+		{name: "plain error", err: errors.New("boom"), want: ReasonUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReasonOf(tt.err); got != tt.want {
+				t.Errorf("ReasonOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithReasonNil(t *testing.T) {
+	if err := WithReason(ReasonAuth, nil); err != nil {
+		t.Errorf("WithReason(nil) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,89 @@
+package source
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// health holds the descriptors of the fetch health metrics of one source. The source and instance
+// are constant labels, so every source describes and exports its own series from its Collect.
+type health struct {
+	up          *prometheus.Desc
+	lastSuccess *prometheus.Desc
+	resultAge   *prometheus.Desc
+	errors      *prometheus.Desc
+	duration    *prometheus.Desc
+}
+
+// newHealth creates the fetch health descriptors of the source name and instance
+func newHealth(name, instance string) health {
+	constLabels := prometheus.Labels{"source": name, "instance": instance}
+	return health{
+		up: prometheus.NewDesc(
+			"license_source_up",
+			"Whether the last license fetch from the source succeeded",
+			nil,
+			constLabels,
+		),
+		lastSuccess: prometheus.NewDesc(
+			"license_source_last_success_timestamp_seconds",
+			"Unix timestamp of the last successful license fetch from the source",
+			nil,
+			constLabels,
+		),
+		resultAge: prometheus.NewDesc(
+			"license_source_result_age_seconds",
+			"Age of the license values exported for the source, above the cache TTL while a failing source is served from its last successful fetch",
+			nil,
+			constLabels,
+		),
+		errors: prometheus.NewDesc(
+			"license_source_errors_total",
+			"Failed license fetches from the source by reason",
+			[]string{"reason"},
+			constLabels,
+		),
+		duration: prometheus.NewDesc(
+			"license_source_scrape_duration_seconds",
+			"Duration of the last license fetch from the source",
+			nil,
+			constLabels,
+		),
+	}
+}
+
+// describe sends the fetch health descriptors
+func (h health) describe(ch chan<- *prometheus.Desc) {
+	ch <- h.up
+	ch <- h.lastSuccess
+	ch <- h.resultAge
+	ch <- h.errors
+	ch <- h.duration
+}
+
+// status is the fetch health of a source as of one Cache.Get, so it matches the result served with it
+type status struct {
+	up          bool
+	lastSuccess time.Time
+	duration    time.Duration
+	// errors counts the failed fetches by reason since startup
+	errors map[string]float64
+}
+
+// collect sends the fetch health of st as constant metrics
+func (h health) collect(ch chan<- prometheus.Metric, st status) {
+	up := 0.0
+	if st.up {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(h.up, prometheus.GaugeValue, up)
+	if !st.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(h.lastSuccess, prometheus.GaugeValue, float64(st.lastSuccess.UnixNano())/1e9)
+		ch <- prometheus.MustNewConstMetric(h.resultAge, prometheus.GaugeValue, time.Since(st.lastSuccess).Seconds())
+	}
+	for reason, count := range st.errors {
+		ch <- prometheus.MustNewConstMetric(h.errors, prometheus.CounterValue, count, reason)
+	}
+	ch <- prometheus.MustNewConstMetric(h.duration, prometheus.GaugeValue, st.duration.Seconds())
+}
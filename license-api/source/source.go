@@ -0,0 +1,53 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Result is the vendor-neutral view of a license returned by every Source. Quantity is what the
+// license is sized by, users or lines of code, and 0 when unknown or unlimited.
+// Details holds the vendor license the Source exports its own metrics from.
+// Sources that collect other data, such as the GitLab token inventory, only set Details.
+type Result struct {
+	Plan      string
+	ExpiresAt time.Time
+	Expired   bool
+	Quantity  int
+	Details   interface{}
+}
+
+// DaysUntilExpiry returns the days left until the license expires, computed against the current time
+func (r *Result) DaysUntilExpiry() int {
+	return int(time.Until(r.ExpiresAt).Hours() / 24)
+}
+
+// CheckApplied compares the license a product reports once a key is applied with the expiry, by UTC
+// date, and the quantity the key was declared with. A declared quantity of 0 is not compared.
+func CheckApplied(declared, reported *Result) error {
+	const layout = "2006-01-02"
+	if declared.ExpiresAt.UTC().Format(layout) == reported.ExpiresAt.UTC().Format(layout) &&
+		(declared.Quantity == 0 || declared.Quantity == reported.Quantity) {
+		return nil
+	}
+	return WithReason(ReasonMismatch, fmt.Errorf("applied license expires %s with quantity %d, declared %s with quantity %d",
+		reported.ExpiresAt.UTC().Format(layout), reported.Quantity, declared.ExpiresAt.UTC().Format(layout), declared.Quantity))
+}
+
+// Source is implemented by each license vendor package (gitlab, nexus, sonar) and by
+// additional vendor collectors. Sources are collectors that fetch on scrape through a Cache.
+type Source interface {
+	prometheus.Collector
+
+	// Name returns the vendor or collector name, e.g. "gitlab" or "gitlab_tokens".
+	Name() string
+
+	// Instance returns the configured instance name, e.g. "prod".
+	Instance() string
+
+	// Fetch retrieves the current license from the upstream API and returns the normalized result.
+	Fetch(ctx context.Context) (*Result, error)
+}
@@ -0,0 +1,33 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckApplied(t *testing.T) {
+	expiry := time.Date(2027, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		declared *Result
+		reported *Result
+		wantErr  bool
+	}{
+		{name: "same expiry", declared: &Result{ExpiresAt: expiry}, reported: &Result{ExpiresAt: expiry, Quantity: 500}},
+		{name: "same day in another zone", declared: &Result{ExpiresAt: expiry}, reported: &Result{ExpiresAt: expiry.Add(23 * time.Hour).In(time.FixedZone("CEST", 2*3600))}},
+		{name: "same expiry and quantity", declared: &Result{ExpiresAt: expiry, Quantity: 500}, reported: &Result{ExpiresAt: expiry, Quantity: 500}},
+		{name: "other expiry", declared: &Result{ExpiresAt: expiry}, reported: &Result{ExpiresAt: expiry.AddDate(-1, 0, 0)}, wantErr: true},
+		{name: "other quantity", declared: &Result{ExpiresAt: expiry, Quantity: 500}, reported: &Result{ExpiresAt: expiry, Quantity: 400}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckApplied(tt.declared, tt.reported)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckApplied() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && ReasonOf(err) != ReasonMismatch {
+				t.Errorf("CheckApplied() reason = %q, want %q", ReasonOf(err), ReasonMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package vault
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Prometheus metrics
+var (
+	authFailuresMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_auth_failures_total",
+			Help: "Failed Vault logins by auth method",
+		},
+		[]string{"method"},
+	)
+	renewalsMetric = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "vault_token_renewals_total",
+			Help: "Successful renewals of the exporter's Vault token",
+		},
+	)
+)
+
+func init() {
+	// Register metrics with Prometheus
+	prometheus.MustRegister(authFailuresMetric)
+	prometheus.MustRegister(renewalsMetric)
+}
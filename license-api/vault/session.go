@@ -0,0 +1,219 @@
+package vault
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Backoff between failed logins of a Session
+const (
+	minLoginBackoff = 5 * time.Second
+	maxLoginBackoff = 5 * time.Minute
+)
+
+// Session keeps one Vault client logged in for the life of the exporter. It logs in once,
+// renews the token through a lifetime watcher, logs in again when renewal stops and revokes
+// the token on shutdown, unless the operator supplied it. Only one Session may exist per process as it registers vault_token_ttl_seconds.
+type Session struct {
+	client *api.Client
+	auth   config.VaultAuth
+	logins singleflight.Group
+
+	mu        sync.Mutex
+	secret    *api.Secret
+	expiresAt time.Time
+}
+
+// NewSession creates the Vault client of the session, the first login happens on first use or in Run
+func NewSession(cfg config.Vault) (*Session, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{client: client, auth: cfg.Auth}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "vault_token_ttl_seconds",
+			Help: "Seconds until the exporter's Vault token expires, +Inf for tokens without expiry and 0 when logged out",
+		},
+		s.ttl,
+	))
+	return s, nil
+}
+
+// Client returns the Vault client of the session.
+func (s *Session) Client() *api.Client {
+	return s.client
+}
+
+// Login logs in unless the session already holds a token. It matches secretstore.LoginFunc so
+// stores built on the session client only trigger a login when there is no valid token.
+func (s *Session) Login(ctx context.Context, _ *api.Client) error {
+	_, err := s.current(ctx)
+	return err
+}
+
+// Run keeps the session token alive until ctx is done, then revokes it unless it was supplied with the token auth method.
+func (s *Session) Run(ctx context.Context) {
+	backoff := minLoginBackoff
+	for {
+		secret, err := s.current(ctx)
+		if err != nil {
+			log.Printf("Vault login failed, retrying in %s: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxLoginBackoff)
+			continue
+		}
+		backoff = minLoginBackoff
+
+		if err := s.watch(ctx, secret); err != nil {
+			log.Printf("Vault token renewal stopped, logging in again: %v", err)
+		}
+		if ctx.Err() != nil {
+			s.revoke()
+			return
+		}
+		s.invalidate(secret)
+	}
+}
+
+// current returns the token of the session, logging in when there is none. Concurrent callers share
+// one login, which runs on a clone of the client without holding s.mu, so a slow Vault does not block
+// the token TTL or renewals. The token is swapped into the session client once the login succeeded.
+func (s *Session) current(ctx context.Context) (*api.Secret, error) {
+	s.mu.Lock()
+	secret := s.secret
+	s.mu.Unlock()
+	if secret != nil {
+		return secret, nil
+	}
+
+	v, err, _ := s.logins.Do("login", func() (interface{}, error) {
+		// A login that finished while this caller waited for the group needs no second login
+		s.mu.Lock()
+		secret := s.secret
+		s.mu.Unlock()
+		if secret != nil {
+			return secret, nil
+		}
+
+		loginClient, err := s.client.CloneWithHeaders()
+		if err != nil {
+			return nil, err
+		}
+		secret, err = Login(ctx, loginClient, s.auth)
+		if err != nil {
+			authFailuresMetric.WithLabelValues(s.auth.Method).Inc()
+			return nil, err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.client.SetToken(secret.Auth.ClientToken)
+		s.secret = secret
+		s.setExpiry(secret.Auth.LeaseDuration)
+		log.Printf("Logged in to Vault using the %s auth method", s.auth.Method)
+		return secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*api.Secret), nil
+}
+
+// watch renews the token until renewal stops or ctx is done
+func (s *Session) watch(ctx context.Context, secret *api.Secret) error {
+	// Tokens without a TTL never expire, there is nothing to renew
+	if secret.Auth.LeaseDuration == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	watcher, err := s.client.NewLifetimeWatcher(&api.LifetimeWatcherInput{Secret: secret})
+	if err != nil {
+		return err
+	}
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.DoneCh():
+			// The token reached its max TTL, was not renewable or renewal failed
+			return err
+		case renewal := <-watcher.RenewCh():
+			renewalsMetric.Inc()
+			s.mu.Lock()
+			s.setExpiry(renewal.Secret.Auth.LeaseDuration)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// invalidate drops the token so the next use logs in again, unless another login already replaced it
+func (s *Session) invalidate(secret *api.Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.secret == secret {
+		s.secret = nil
+		s.expiresAt = time.Time{}
+	}
+}
+
+// revoke revokes the session token so it does not outlive the exporter. A token supplied with the
+// token auth method is not the exporter's to revoke, the next start would be locked out of Vault.
+func (s *Session) revoke() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.secret == nil || s.auth.Method == config.AuthToken {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.client.Auth().Token().RevokeSelfWithContext(ctx, ""); err != nil {
+		log.Printf("Failed to revoke Vault token: %v", err)
+	} else {
+		log.Printf("Revoked Vault token")
+	}
+	s.secret = nil
+	s.expiresAt = time.Time{}
+}
+
+// setExpiry records when the token expires, called with s.mu held
+func (s *Session) setExpiry(leaseDuration int) {
+	if leaseDuration == 0 {
+		s.expiresAt = time.Time{}
+		return
+	}
+	s.expiresAt = time.Now().Add(time.Duration(leaseDuration) * time.Second)
+}
+
+// ttl returns the seconds left on the token for vault_token_ttl_seconds
+func (s *Session) ttl() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.secret == nil:
+		return 0
+	case s.expiresAt.IsZero():
+		return math.Inf(1)
+	default:
+		return math.Max(0, time.Until(s.expiresAt).Seconds())
+	}
+}
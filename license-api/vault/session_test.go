@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/hashicorp/vault/api"
+)
+
+func TestSessionLoginOutsideLock(t *testing.T) {
+	var logins atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/approle/login" {
+			http.NotFound(w, r)
+			return
+		}
+		logins.Add(1)
+		<-release
+		w.Write([]byte(`{"auth":{"client_token":"s.session","lease_duration":3600,"renewable":true}}`))
+	}))
+	defer server.Close()
+
+	roleID := filepath.Join(t.TempDir(), "role-id")
+	if err := os.WriteFile(roleID, []byte("role"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := api.DefaultConfig()
+	cfg.Address = server.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ClearToken()
+	s := &Session{client: client, auth: config.VaultAuth{Method: config.AuthAppRole, Mount: "approle", RoleIDFile: roleID}}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.current(context.Background())
+			errs <- err
+		}()
+	}
+	for logins.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The TTL is read under the session lock, which the pending login must not hold
+	ttl := make(chan float64)
+	go func() { ttl <- s.ttl() }()
+	select {
+	case got := <-ttl:
+		if got != 0 {
+			t.Errorf("ttl() = %v during the first login, want 0", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ttl() blocked on the pending login")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("current() error = %v", err)
+		}
+	}
+	if n := logins.Load(); n != 1 {
+		t.Errorf("logged in %d times for %d concurrent callers, want 1", n, callers)
+	}
+	if token := client.Token(); token != "s.session" {
+		t.Errorf("session client token = %q, want the login token", token)
+	}
+	if got := s.ttl(); got <= 0 {
+		t.Errorf("ttl() = %v after login, want the lease duration", got)
+	}
+}
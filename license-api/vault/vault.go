@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gauravkr19/prometheus-exporters/config"
+	"github.com/hashicorp/vault/api"
+)
+
+// NewClient creates a Vault client with the configured address and TLS settings
+func NewClient(cfg config.Vault) (*api.Client, error) {
+	clientConfig := api.DefaultConfig()
+	clientConfig.Address = cfg.URL
+	tlsConfig := &api.TLSConfig{
+		CACert:        cfg.TLS.CAFile,
+		ClientCert:    cfg.TLS.CertFile,
+		ClientKey:     cfg.TLS.KeyFile,
+		TLSServerName: cfg.TLS.ServerName,
+		Insecure:      cfg.TLS.InsecureSkipVerify,
+	}
+	if err := clientConfig.ConfigureTLS(tlsConfig); err != nil {
+		return nil, fmt.Errorf("error configuring Vault TLS: %w", err)
+	}
+
+	client, err := api.NewClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Vault client: %w", err)
+	}
+
+	// The token is set by Login, never picked up implicitly from VAULT_TOKEN
+	client.ClearToken()
+	return client, nil
+}
+
+// Login authenticates the client with the configured auth method and sets the resulting token on it.
+// The returned secret carries the token's lease information in its Auth field.
+func Login(ctx context.Context, client *api.Client, auth config.VaultAuth) (*api.Secret, error) {
+	if auth.Method == config.AuthToken {
+		return loginToken(ctx, client, auth)
+	}
+
+	authData, err := loginData(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, auth.LoginPath(), authData)
+	if err != nil {
+		return nil, fmt.Errorf("error authenticating with Vault using %s: %w", auth.Method, err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, fmt.Errorf("error authenticating with Vault: no auth info returned from %s", auth.LoginPath())
+	}
+
+	// Set the Vault token from the authentication response
+	client.SetToken(secret.Auth.ClientToken)
+	return secret, nil
+}
+
+// loginData builds the login request body of the auth method
+func loginData(auth config.VaultAuth) (map[string]interface{}, error) {
+	switch auth.Method {
+	case config.AuthJWT, config.AuthKubernetes:
+		jwt, err := readFile(auth.JWTFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading service account token: %w", err)
+		}
+		return map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  jwt,
+		}, nil
+	case config.AuthAppRole:
+		roleID, err := readFile(auth.RoleIDFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading AppRole role_id: %w", err)
+		}
+		data := map[string]interface{}{"role_id": roleID}
+		if auth.SecretIDFile != "" {
+			secretID, err := readFile(auth.SecretIDFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading AppRole secret_id: %w", err)
+			}
+			data["secret_id"] = secretID
+		}
+		return data, nil
+	case config.AuthCert:
+		// The client certificate configured on the Vault TLS settings is the credential
+		data := map[string]interface{}{}
+		if auth.Role != "" {
+			data["name"] = auth.Role
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown Vault auth method %q", auth.Method)
+	}
+}
+
+// loginToken uses a static token, looking it up to validate it and learn its TTL
+func loginToken(ctx context.Context, client *api.Client, auth config.VaultAuth) (*api.Secret, error) {
+	token := auth.Token
+	if token == "" {
+		var err error
+		if token, err = readFile(auth.TokenFile); err != nil {
+			return nil, fmt.Errorf("error reading Vault token: %w", err)
+		}
+	}
+	client.SetToken(token)
+
+	lookup, err := client.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up Vault token: %w", err)
+	}
+	ttl, err := lookup.TokenTTL()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Vault token TTL: %w", err)
+	}
+	renewable, err := lookup.TokenIsRenewable()
+	if err != nil {
+		return nil, fmt.Errorf("error reading Vault token renewability: %w", err)
+	}
+
+	// Present the lookup like a login response so the token can be renewed the same way
+	return &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken:   token,
+			LeaseDuration: int(ttl.Seconds()),
+			Renewable:     renewable,
+		},
+	}, nil
+}
+
+// readFile returns the trimmed content of a credential file, read on every login so rotated credentials are picked up
+func readFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
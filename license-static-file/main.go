@@ -90,6 +90,34 @@ var (
 		},
 		[]string{"software"},
 	)
+	poExpiryTimestampGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "po_expiry_timestamp_seconds",
+			Help: "Unix timestamp of PO expiration",
+		},
+		[]string{"software"},
+	)
+	eolTimestampGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "eol_timestamp_seconds",
+			Help: "Unix timestamp of End of Life",
+		},
+		[]string{"software"},
+	)
+	eosTimestampGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "eos_timestamp_seconds",
+			Help: "Unix timestamp of End of Support",
+		},
+		[]string{"software"},
+	)
+	licenseExpiryTimestampGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "license_expiry_timestamp_seconds",
+			Help: "Unix timestamp of License Expiry",
+		},
+		[]string{"software"},
+	)
 	vendorSupportGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "vendor_support",
@@ -106,6 +134,10 @@ var (
 	)
 )
 
+// exportedLicenses remembers the licenses exported by the last update, keyed by their unique name,
+// so their series can be deleted once an entry changes or is removed from the file
+var exportedLicenses = map[string]License{}
+
 func init() {
 	// Register Prometheus metrics
 	prometheus.MustRegister(licenseVersionGauge)
@@ -116,31 +148,64 @@ func init() {
 	prometheus.MustRegister(totalCapacityGauge)
 	prometheus.MustRegister(currentUtilizationGauge)
 	prometheus.MustRegister(licenseExpiryDateGauge)
+	prometheus.MustRegister(poExpiryTimestampGauge)
+	prometheus.MustRegister(eolTimestampGauge)
+	prometheus.MustRegister(eosTimestampGauge)
+	prometheus.MustRegister(licenseExpiryTimestampGauge)
 	prometheus.MustRegister(vendorSupportGauge)
 	prometheus.MustRegister(poRenewalOwnerGauge)
 }
 
+// readLicenseInfo reads the license file. Every license needs a unique name, which is the software
+// label of its series, a file with a missing or duplicate name is rejected and the last one kept.
 func readLicenseInfo(filePath string) (LicenseInfo, error) {
 	var licenseInfo LicenseInfo
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return licenseInfo, err
 	}
-	err = yaml.Unmarshal(data, &licenseInfo)
-	return licenseInfo, err
+	if err := yaml.Unmarshal(data, &licenseInfo); err != nil {
+		return licenseInfo, err
+	}
+
+	seen := make(map[string]bool, len(licenseInfo.Licenses))
+	for i, license := range licenseInfo.Licenses {
+		if license.Name == "" {
+			return LicenseInfo{}, fmt.Errorf("licenses[%d]: name must not be empty", i)
+		}
+		if seen[license.Name] {
+			return LicenseInfo{}, fmt.Errorf("licenses[%d]: duplicate name %q", i, license.Name)
+		}
+		seen[license.Name] = true
+	}
+	return licenseInfo, nil
 }
 
-func parseDate(dateStr string) (float64, error) {
+func parseTime(dateStr string) (time.Time, error) {
 	if dateStr == "NA" || dateStr == "" {
-		return 0, fmt.Errorf("invalid date")
+		return time.Time{}, fmt.Errorf("invalid date")
 	}
-	date, err := time.Parse("2006-01-02", dateStr)
+	return time.Parse("2006-01-02", dateStr)
+}
+
+func parseDate(dateStr string) (float64, error) {
+	date, err := parseTime(dateStr)
 	if err != nil {
 		return 0, err
 	}
 	return time.Until(date).Hours() / 24, nil
 }
 
+// setTimestamp exports the date as a Unix timestamp, dropping the series when the date is invalid
+func setTimestamp(gauge *prometheus.GaugeVec, name, dateStr string) {
+	date, err := parseTime(dateStr)
+	if err != nil {
+		gauge.DeleteLabelValues(name)
+		return
+	}
+	gauge.WithLabelValues(name).Set(float64(date.Unix()))
+}
+
 func parseFloat(str string) (float64, error) {
 	if str == "" {
 		return 0, fmt.Errorf("invalid number")
@@ -148,7 +213,44 @@ func parseFloat(str string) (float64, error) {
 	return strconv.ParseFloat(str, 64)
 }
 
+// deleteStaleSeries drops the series of licenses removed from the file and the old
+// label values of licenses whose version, renewal owner or vendor support changed
+func deleteStaleSeries(licenses []License) {
+	current := make(map[string]License, len(licenses))
+	for _, license := range licenses {
+		current[license.Name] = license
+	}
+
+	for name, old := range exportedLicenses {
+		license, ok := current[name]
+		if !ok {
+			for _, gauge := range []*prometheus.GaugeVec{
+				licenseVersionGauge, poNumberGauge, poExpiryDateGauge, eolDateGauge, eosDateGauge,
+				totalCapacityGauge, currentUtilizationGauge, licenseExpiryDateGauge, poExpiryTimestampGauge,
+				eolTimestampGauge, eosTimestampGauge, licenseExpiryTimestampGauge, vendorSupportGauge, poRenewalOwnerGauge,
+			} {
+				gauge.DeletePartialMatch(prometheus.Labels{"software": name})
+			}
+			continue
+		}
+		if license.Version != old.Version {
+			licenseVersionGauge.DeleteLabelValues(name, old.Version)
+		}
+		if license.PORenewalOwner != old.PORenewalOwner {
+			poRenewalOwnerGauge.DeleteLabelValues(name, old.PORenewalOwner)
+		}
+		if license.VendorSupport != old.VendorSupport {
+			vendorSupportGauge.DeleteLabelValues(name, old.VendorSupport)
+		}
+	}
+
+	exportedLicenses = current
+}
+
 func updateMetrics(licenses []License, invalidDateLog map[string]bool) {
+	// Only the licenses currently in the file are exported
+	deleteStaleSeries(licenses)
+
 	for _, license := range licenses {
 		name := license.Name
 		version := license.Version
@@ -190,6 +292,10 @@ func updateMetrics(licenses []License, invalidDateLog map[string]bool) {
 		totalCapacityGauge.WithLabelValues(name).Set(totalCapacity)
 		currentUtilizationGauge.WithLabelValues(name).Set(currentUtilization)
 		licenseExpiryDateGauge.WithLabelValues(name).Set(daysUntilLicenseExpiry)
+		setTimestamp(poExpiryTimestampGauge, name, license.POExpiryDate)
+		setTimestamp(eolTimestampGauge, name, license.EOLDate)
+		setTimestamp(eosTimestampGauge, name, license.EOSDate)
+		setTimestamp(licenseExpiryTimestampGauge, name, license.LicenseExpiryDate)
 		// poRenewalOwnerGauge.WithLabelValues(name).Set(poRenewalOwner)
 		poRenewalOwnerGauge.WithLabelValues(name, license.PORenewalOwner).Set(1)
 		vendorSupportGauge.WithLabelValues(name, license.VendorSupport).Set(1) // with label